@@ -0,0 +1,390 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+const defaultConditionSeverity = "warning"
+
+// The states of a condition, as exported in openmeteo_alert_state.
+const (
+	alertStateInactive = "inactive"
+	alertStatePending  = "pending"
+	alertStateFiring   = "firing"
+)
+
+var alertStates = []string{alertStateInactive, alertStatePending, alertStateFiring}
+
+// The statuses of a notification.
+const (
+	notificationFiring   = "firing"
+	notificationResolved = "resolved"
+)
+
+var conditionOperators = []string{">", ">=", "<", "<=", "==", "!="}
+
+var alertStateDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "alert", "state"),
+	"The state of each condition evaluated by the exporter, 1 for the current state and 0 for the others.",
+	[]string{"location", "alert", "severity", "state"},
+	nil,
+)
+
+// ConditionConfig is an alert evaluated by the exporter on every poll, e.g.
+// wind_gusts_10m > 50 for 30m. The threshold is in the unit the location
+// exports the variable in.
+type ConditionConfig struct {
+	Name string `yaml:"name,omitempty"`
+	// A variable of the location, a comparison operator and a threshold,
+	// optionally followed by for and a duration.
+	Expr     string            `yaml:"expr,omitempty"`
+	For      time.Duration     `yaml:"for,omitempty"`
+	Severity string            `yaml:"severity,omitempty"`
+	Labels   map[string]string `yaml:"labels,omitempty"`
+
+	// Set once the expression has been parsed.
+	API       string  `yaml:"-"`
+	Variable  string  `yaml:"-"`
+	Operator  string  `yaml:"-"`
+	Threshold float64 `yaml:"-"`
+}
+
+// AlertingConfig configures where the exporter sends notifications about
+// the conditions of the locations.
+type AlertingConfig struct {
+	Webhooks      []WebhookConfig      `yaml:"webhooks,omitempty"`
+	Alertmanagers []AlertmanagerConfig `yaml:"alertmanagers,omitempty"`
+}
+
+func (c *AlertingConfig) Validate() error {
+	var errs []error
+	names := make(map[string]bool)
+	for i := range c.Webhooks {
+		w := &c.Webhooks[i]
+		if err := w.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if names[w.Name] {
+			errs = append(errs, fmt.Errorf("invalid webhook config, duplicate name %s, set a unique name for each webhook", w.Name))
+		}
+		names[w.Name] = true
+	}
+	for i := range c.Alertmanagers {
+		am := &c.Alertmanagers[i]
+		if err := am.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if names[am.Name] {
+			errs = append(errs, fmt.Errorf("invalid alertmanager config, duplicate name %s, set a unique name for each webhook and alertmanager", am.Name))
+		}
+		names[am.Name] = true
+	}
+	return errors.Join(errs...)
+}
+
+// Parses the expression of the condition, which must use one of the
+// variables of the location.
+func (c *ConditionConfig) Validate(l *LocationConfig, location string) []error {
+	var errs []error
+	if len(c.Name) == 0 {
+		errs = append(errs, fmt.Errorf("invalid condition, no name provided, for location: %s", location))
+	}
+
+	fields := strings.Fields(c.Expr)
+	if len(fields) == 5 && fields[3] == "for" {
+		d, err := model.ParseDuration(fields[4])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid condition %s, bad duration %s, for location: %s", c.Name, fields[4], location))
+		} else if c.For != 0 && c.For != time.Duration(d) {
+			errs = append(errs, fmt.Errorf("invalid condition %s, for is set in both the expr and the condition, for location: %s", c.Name, location))
+		} else {
+			c.For = time.Duration(d)
+		}
+		fields = fields[:3]
+	}
+	if len(fields) != 3 {
+		return append(errs, fmt.Errorf("invalid condition %s, expr must be <variable> <operator> <threshold> [for <duration>]: %q, for location: %s", c.Name, c.Expr, location))
+	}
+
+	c.Variable = fields[0]
+	c.API = ""
+	for _, api := range []string{"weather", "airquality"} {
+		if slices.Contains(l.variables(api), c.Variable) {
+			c.API = api
+			break
+		}
+	}
+	if c.API == "" {
+		errs = append(errs, fmt.Errorf("invalid condition %s, %s is not a variable of the location: %s", c.Name, c.Variable, location))
+	}
+
+	c.Operator = fields[1]
+	if !slices.Contains(conditionOperators, c.Operator) {
+		errs = append(errs, fmt.Errorf("invalid condition %s, operator must be one of %s: %s, for location: %s", c.Name, strings.Join(conditionOperators, " "), c.Operator, location))
+	}
+
+	threshold, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid condition %s, bad threshold %s, for location: %s", c.Name, fields[2], location))
+	}
+	c.Threshold = threshold
+
+	if c.For < 0 {
+		errs = append(errs, fmt.Errorf("invalid condition %s, for must not be negative, for location: %s", c.Name, location))
+	}
+	errs = append(errs, validateAlertLabels(c.Labels, fmt.Sprintf("condition %s of location: %s", c.Name, location))...)
+	if len(c.Severity) == 0 {
+		c.Severity = defaultConditionSeverity
+	} else if !slices.Contains(ValidAlertSeverities, c.Severity) {
+		errs = append(errs, fmt.Errorf("invalid severity, %s, for condition %s of location: %s", c.Severity, c.Name, location))
+	}
+	return errs
+}
+
+// The labels set by the exporter on the alerts it sends.
+var reservedAlertLabels = []string{"alertname", "location", "severity"}
+
+// Checks that custom labels of alerts are valid label names and don't
+// override the labels set by the exporter.
+func validateAlertLabels(labels map[string]string, owner string) []error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		if !model.LabelName(name).IsValid() {
+			errs = append(errs, fmt.Errorf("invalid label name, %s, for %s", name, owner))
+		} else if slices.Contains(reservedAlertLabels, name) {
+			errs = append(errs, fmt.Errorf("invalid label, %s is set by the exporter, for %s", name, owner))
+		}
+	}
+	return errs
+}
+
+// Matches reports whether a value meets the condition.
+func (c *ConditionConfig) Matches(value float64) bool {
+	switch c.Operator {
+	case ">":
+		return value > c.Threshold
+	case ">=":
+		return value >= c.Threshold
+	case "<":
+		return value < c.Threshold
+	case "<=":
+		return value <= c.Threshold
+	case "==":
+		return value == c.Threshold
+	case "!=":
+		return value != c.Threshold
+	}
+	return false
+}
+
+// Notification is sent when a condition starts firing and when it is
+// resolved.
+type Notification struct {
+	// Either firing or resolved.
+	Status   string `json:"status"`
+	Alert    string `json:"alert"`
+	Location string `json:"location"`
+	Severity string `json:"severity"`
+	// The labels of the location and of the condition.
+	Labels   map[string]string `json:"labels,omitempty"`
+	Expr     string            `json:"expr"`
+	Variable string            `json:"variable"`
+	// The description of the variable from the variable list.
+	Description string  `json:"description"`
+	Value       float64 `json:"value"`
+	Unit        string  `json:"unit"`
+	Threshold   float64 `json:"threshold"`
+	// The time the condition was first met.
+	StartsAt time.Time `json:"starts_at"`
+	// Only set once resolved.
+	EndsAt *time.Time `json:"ends_at,omitempty"`
+}
+
+// Notifier delivers notifications, e.g. to a webhook.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// The state of a condition of a location.
+type conditionState struct {
+	Location  string
+	Condition *ConditionConfig
+	Labels    map[string]string
+
+	State string
+	// When the condition was first met, zero while inactive.
+	ActiveAt time.Time
+	// The last value the condition was evaluated with.
+	Value float64
+	Unit  string
+}
+
+func (s *conditionState) notification(status string) *Notification {
+	return &Notification{
+		Status:    status,
+		Alert:     s.Condition.Name,
+		Location:  s.Location,
+		Severity:  s.Condition.Severity,
+		Labels:    s.Labels,
+		Expr:      s.Condition.Expr,
+		Variable:  s.Condition.Variable,
+		Value:     s.Value,
+		Unit:      s.Unit,
+		Threshold: s.Condition.Threshold,
+		StartsAt:  s.ActiveAt,
+
+		Description: variableDescription(s.Condition.API, s.Condition.Variable),
+	}
+}
+
+// AlertEngine evaluates the conditions of the locations on every poll and
+// notifies the notifiers when they start firing and when they are resolved.
+type AlertEngine struct {
+	Notifiers []Notifier
+
+	// Returns the time of an evaluation, time.Now if nil.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	states []*conditionState
+}
+
+func NewAlertEngine(config *Config, notifiers []Notifier) *AlertEngine {
+	e := &AlertEngine{Notifiers: notifiers}
+	for i := range config.Locations {
+		loc := &config.Locations[i]
+		for j := range loc.Conditions {
+			// The labels of the condition take precedence.
+			labels := maps.Clone(loc.Labels)
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			maps.Copy(labels, loc.Conditions[j].Labels)
+
+			e.states = append(e.states, &conditionState{
+				Location:  loc.Name,
+				Condition: &loc.Conditions[j],
+				Labels:    labels,
+				State:     alertStateInactive,
+			})
+		}
+	}
+	return e
+}
+
+func (e *AlertEngine) Name() string {
+	return "alerting"
+}
+
+// Write evaluates the conditions with the polled data. Conditions of
+// locations whose variable has no value, e.g. because the request failed,
+// keep their state.
+func (e *AlertEngine) Write(ctx context.Context, data []*LocationData) error {
+	now := time.Now()
+	if e.Now != nil {
+		now = e.Now()
+	}
+
+	samples := make(map[string]Sample)
+	for _, d := range data {
+		for _, sample := range d.Samples() {
+			samples[sample.Location+"\x00"+sample.Variable] = sample
+		}
+	}
+
+	var notifications []*Notification
+	e.mu.Lock()
+	for _, s := range e.states {
+		sample, ok := samples[s.Location+"\x00"+s.Condition.Variable]
+		if !ok {
+			continue
+		}
+		s.Value = sample.Value
+		s.Unit = sample.Unit
+
+		if !s.Condition.Matches(sample.Value) {
+			if s.State == alertStateFiring {
+				n := s.notification(notificationResolved)
+				n.EndsAt = &now
+				notifications = append(notifications, n)
+				level.Info(logger).Log("msg", "Alert resolved", "location", s.Location, "alert", s.Condition.Name, "value", sample.Value)
+			}
+			s.State = alertStateInactive
+			s.ActiveAt = time.Time{}
+			continue
+		}
+
+		if s.State == alertStateInactive {
+			s.State = alertStatePending
+			s.ActiveAt = now
+		}
+		if s.State == alertStatePending && now.Sub(s.ActiveAt) >= s.Condition.For {
+			s.State = alertStateFiring
+			notifications = append(notifications, s.notification(notificationFiring))
+			level.Info(logger).Log("msg", "Alert firing", "location", s.Location, "alert", s.Condition.Name, "value", sample.Value)
+		}
+	}
+	e.mu.Unlock()
+
+	var errs []error
+	for _, n := range notifications {
+		for _, notifier := range e.Notifiers {
+			if err := notifier.Notify(ctx, n); err != nil {
+				alertNotificationsTotal.WithLabelValues(notifier.Name(), "error").Inc()
+				errs = append(errs, fmt.Errorf("failed to notify %s of alert %s for %s: %w", notifier.Name(), n.Alert, n.Location, err))
+				continue
+			}
+			alertNotificationsTotal.WithLabelValues(notifier.Name(), "success").Inc()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (e *AlertEngine) Describe(ch chan<- *prometheus.Desc) {
+	ch <- alertStateDesc
+}
+
+func (e *AlertEngine) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, s := range e.states {
+		for _, state := range alertStates {
+			value := 0.0
+			if state == s.State {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(alertStateDesc, prometheus.GaugeValue, value, s.Location, s.Condition.Name, s.Condition.Severity, state)
+		}
+	}
+}
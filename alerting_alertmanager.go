@@ -0,0 +1,239 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/version"
+)
+
+const (
+	alertmanagerAlertsPath = "/api/v2/alerts"
+
+	defaultAlertmanagerTimeout        = 10 * time.Second
+	defaultAlertmanagerResendInterval = time.Minute
+
+	// Like Prometheus, keep sending resolved alerts for a while in case
+	// Alertmanager missed the first resolution.
+	alertmanagerResolvedRetention = 15 * time.Minute
+)
+
+// AlertmanagerConfig is an Alertmanager the alerts of the conditions are
+// pushed to.
+type AlertmanagerConfig struct {
+	// Identifies the Alertmanager in logs and metrics, the host of the URL
+	// if empty.
+	Name string `yaml:"name,omitempty"`
+	// Base URL of the Alertmanager, e.g. http://localhost:9093.
+	URL         string `yaml:"url,omitempty"`
+	BearerToken string `yaml:"bearer_token,omitempty"`
+	// How often firing alerts are sent again, which keeps Alertmanager from
+	// resolving them.
+	ResendInterval time.Duration `yaml:"resend_interval,omitempty"`
+	Timeout        time.Duration `yaml:"timeout,omitempty"`
+}
+
+func (c *AlertmanagerConfig) Validate() error {
+	var errs []error
+	if len(c.URL) == 0 {
+		errs = append(errs, errors.New("invalid alertmanager config, no url provided"))
+	} else if u, err := url.Parse(c.URL); err != nil || u.Host == "" {
+		errs = append(errs, fmt.Errorf("invalid alertmanager config, bad url: %s", c.URL))
+	} else if len(c.Name) == 0 {
+		c.Name = u.Host
+	}
+
+	if c.ResendInterval <= 0 {
+		c.ResendInterval = defaultAlertmanagerResendInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultAlertmanagerTimeout
+	}
+
+	return errors.Join(errs...)
+}
+
+// An alert in the format of the Alertmanager API.
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+
+	// When the alert was resolved, zero while firing.
+	resolvedAt time.Time
+}
+
+// Converts a notification into an Alertmanager alert, with the labels of the
+// location and of the condition and the description of the variable.
+func newAlertmanagerAlert(n *Notification) *alertmanagerAlert {
+	labels := maps.Clone(n.Labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels["alertname"] = n.Alert
+	labels["location"] = n.Location
+	labels["severity"] = n.Severity
+
+	value := strconv.FormatFloat(n.Value, 'f', -1, 64)
+	return &alertmanagerAlert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary":     fmt.Sprintf("%s in %s: %s is %s", n.Alert, n.Location, n.Variable, strings.TrimSpace(value+" "+n.Unit)),
+			"description": n.Description,
+			"expr":        n.Expr,
+			"value":       value,
+		},
+		StartsAt: n.StartsAt,
+	}
+}
+
+// Alertmanager pushes the alerts of the conditions to an Alertmanager. The
+// firing and recently resolved alerts are sent on every notification and
+// every resend interval.
+type Alertmanager struct {
+	Config *AlertmanagerConfig
+	Client *http.Client
+
+	// Returns the current time, time.Now if nil.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	alerts map[string]*alertmanagerAlert
+}
+
+func NewAlertmanager(cfg *AlertmanagerConfig) *Alertmanager {
+	return &Alertmanager{
+		Config: cfg,
+		Client: &http.Client{Timeout: cfg.Timeout},
+		alerts: make(map[string]*alertmanagerAlert),
+	}
+}
+
+func (a *Alertmanager) Name() string {
+	return a.Config.Name
+}
+
+func (a *Alertmanager) now() time.Time {
+	if a.Now != nil {
+		return a.Now()
+	}
+	return time.Now()
+}
+
+func (a *Alertmanager) Notify(ctx context.Context, n *Notification) error {
+	alert := newAlertmanagerAlert(n)
+	if n.Status == notificationResolved && n.EndsAt != nil {
+		alert.resolvedAt = *n.EndsAt
+	}
+
+	a.mu.Lock()
+	a.alerts[n.Location+"\x00"+n.Alert] = alert
+	a.mu.Unlock()
+
+	return a.send(ctx)
+}
+
+// Run resends the alerts on every resend interval until ctx is done.
+func (a *Alertmanager) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.Config.ResendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := a.send(ctx); err != nil {
+			alertNotificationsTotal.WithLabelValues(a.Name(), "error").Inc()
+			level.Error(logger).Log("msg", "Failed to resend alerts to Alertmanager", "alertmanager", a.Name(), "err", err)
+		}
+	}
+}
+
+// Returns the alerts to send, dropping resolved alerts that were sent long
+// enough. Firing alerts end after a few resend intervals unless they are sent
+// again, so they resolve if the exporter stops.
+func (a *Alertmanager) pending(now time.Time) []alertmanagerAlert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	alerts := make([]alertmanagerAlert, 0, len(a.alerts))
+	for key, alert := range a.alerts {
+		if alert.resolvedAt.IsZero() {
+			alert.EndsAt = now.Add(4 * a.Config.ResendInterval)
+		} else {
+			if now.Sub(alert.resolvedAt) > alertmanagerResolvedRetention {
+				delete(a.alerts, key)
+				continue
+			}
+			alert.EndsAt = alert.resolvedAt
+		}
+		alerts = append(alerts, *alert)
+	}
+	return alerts
+}
+
+func (a *Alertmanager) send(ctx context.Context) error {
+	alerts := a.pending(a.now())
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(a.Config.URL, "/")+alertmanagerAlertsPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "openmeteo_exporter/"+version.Version)
+	if a.Config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Config.BearerToken)
+	}
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("alertmanager returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	level.Debug(logger).Log("msg", "Sent alerts to Alertmanager", "alertmanager", a.Name(), "alerts", len(alerts))
+	return nil
+}
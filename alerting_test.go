@@ -0,0 +1,258 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type recordingNotifier struct {
+	notifications []*Notification
+}
+
+func (n *recordingNotifier) Name() string {
+	return "recording"
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification *Notification) error {
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+// Returns the data of a poll of a location with a wind gusts value.
+func windGustsData(loc *LocationConfig, value float64) []*LocationData {
+	resp := &WeatherResponse{}
+	resp.Current.Variables = map[string]Value{"wind_gusts_10m": {Number: value, Valid: true}}
+	resp.CurrentUnits.Variables = map[string]string{"wind_gusts_10m": "km/h"}
+	return []*LocationData{{Location: loc, Weather: resp}}
+}
+
+func TestConditionValidate(t *testing.T) {
+	loc := testLocation("Nice", []string{"wind_gusts_10m"}, []string{"us_aqi"})
+	tests := []struct {
+		expr string
+		// Substring of the error, valid if empty.
+		err string
+	}{
+		{expr: "wind_gusts_10m > 50"},
+		{expr: "wind_gusts_10m > 50 for 30m"},
+		{expr: "us_aqi >= 151"},
+		{expr: "temperature_2m > 30", err: "temperature_2m is not a variable of the location"},
+		{expr: "us_aqi => 151", err: "operator must be one of"},
+		{expr: "us_aqi >= high", err: "bad threshold high"},
+		{expr: "us_aqi >= 151 for ever", err: "bad duration ever"},
+		{expr: "us_aqi", err: "expr must be"},
+	}
+	for _, tt := range tests {
+		c := ConditionConfig{Name: "Test", Expr: tt.expr}
+		errs := c.Validate(&loc, loc.Name)
+		if tt.err == "" {
+			if len(errs) != 0 {
+				t.Errorf("%q: unexpected errors: %v", tt.expr, errs)
+			}
+			continue
+		}
+		if len(errs) == 0 || !strings.Contains(errs[0].Error(), tt.err) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.expr, tt.err, errs)
+		}
+	}
+
+	c := ConditionConfig{Name: "Wind", Expr: "wind_gusts_10m > 50 for 30m"}
+	if errs := c.Validate(&loc, loc.Name); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if c.API != "weather" || c.Variable != "wind_gusts_10m" || c.Operator != ">" || c.Threshold != 50 || c.For != 30*time.Minute || c.Severity != defaultConditionSeverity {
+		t.Errorf("unexpected parsed condition: %+v", c)
+	}
+}
+
+func TestAlertEngine(t *testing.T) {
+	loc := testLocation("Nice", []string{"wind_gusts_10m"}, nil)
+	loc.Conditions = []ConditionConfig{{Name: "HighWind", Expr: "wind_gusts_10m > 50 for 30m"}}
+	config := &Config{Locations: []LocationConfig{loc}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	notifier := &recordingNotifier{}
+	engine := NewAlertEngine(config, []Notifier{notifier})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	engine.Now = func() time.Time { return now }
+
+	poll := func(value float64, state string, notifications int) {
+		t.Helper()
+		if err := engine.Write(context.Background(), windGustsData(&config.Locations[0], value)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := engine.states[0].State; got != state {
+			t.Errorf("expected state %s at %s, got %s", state, now, got)
+		}
+		if len(notifier.notifications) != notifications {
+			t.Errorf("expected %d notifications at %s, got %d", notifications, now, len(notifier.notifications))
+		}
+		now = now.Add(15 * time.Minute)
+	}
+
+	poll(40, alertStateInactive, 0)
+	poll(60, alertStatePending, 0)
+	poll(65, alertStatePending, 0)
+	poll(70, alertStateFiring, 1)
+	poll(70, alertStateFiring, 1)
+	// A failed request keeps the state.
+	if err := engine.Write(context.Background(), []*LocationData{{Location: &config.Locations[0]}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	poll(30, alertStateInactive, 2)
+
+	firing, resolved := notifier.notifications[0], notifier.notifications[1]
+	if firing.Status != notificationFiring || firing.Value != 70 || firing.Unit != "km/h" || firing.Threshold != 50 {
+		t.Errorf("unexpected firing notification: %+v", firing)
+	}
+	if resolved.Status != notificationResolved || !resolved.StartsAt.Equal(firing.StartsAt) || resolved.EndsAt == nil {
+		t.Errorf("unexpected resolved notification: %+v", resolved)
+	}
+
+	expected := `
+# HELP openmeteo_alert_state The state of each condition evaluated by the exporter, 1 for the current state and 0 for the others.
+# TYPE openmeteo_alert_state gauge
+openmeteo_alert_state{alert="HighWind",location="Nice",severity="warning",state="firing"} 0
+openmeteo_alert_state{alert="HighWind",location="Nice",severity="warning",state="inactive"} 1
+openmeteo_alert_state{alert="HighWind",location="Nice",severity="warning",state="pending"} 0
+`
+	if err := testutil.CollectAndCompare(engine, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWebhookNotify(t *testing.T) {
+	var body, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, auth = string(b), r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	cfg := &WebhookConfig{
+		URL:      server.URL,
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		Template: `{"text": {{ printf "%s %s in %s: %g %s" .Alert .Status .Location .Value .Unit | json }}}`,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	webhook, err := NewWebhook(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	n := &Notification{Status: notificationFiring, Alert: "HighWind", Location: `Nice "Côte d'Azur"`, Value: 70, Unit: "km/h"}
+	if err := webhook.Notify(context.Background(), n); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `{"text": "HighWind firing in Nice \"Côte d'Azur\": 70 km/h"}`; body != want {
+		t.Errorf("expected body %s, got %s", want, body)
+	}
+	if auth != "Bearer token" {
+		t.Errorf("expected the configured headers, got Authorization %q", auth)
+	}
+}
+
+func TestAlertmanagerNotify(t *testing.T) {
+	var posted [][]alertmanagerAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != alertmanagerAlertsPath {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var alerts []alertmanagerAlert
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Errorf("failed to decode alerts: %v", err)
+		}
+		posted = append(posted, alerts)
+	}))
+	defer server.Close()
+
+	cfg := &AlertmanagerConfig{URL: server.URL + "/"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	am := NewAlertmanager(cfg)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	am.Now = func() time.Time { return now }
+
+	loc := testLocation("Nice", []string{"wind_gusts_10m"}, nil)
+	loc.Labels = map[string]string{"team": "sailing", "site": "harbour"}
+	loc.Conditions = []ConditionConfig{{Name: "HighWind", Expr: "wind_gusts_10m > 50", Labels: map[string]string{"site": "pier"}}}
+	config := &Config{Locations: []LocationConfig{loc}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	engine := NewAlertEngine(config, []Notifier{am})
+	engine.Now = am.Now
+
+	if err := engine.Write(context.Background(), windGustsData(&config.Locations[0], 70)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posted) != 1 || len(posted[0]) != 1 {
+		t.Fatalf("expected one alert to be posted, got %+v", posted)
+	}
+	alert := posted[0][0]
+	wantLabels := map[string]string{"alertname": "HighWind", "location": "Nice", "severity": "warning", "team": "sailing", "site": "pier"}
+	if !maps.Equal(alert.Labels, wantLabels) {
+		t.Errorf("expected labels %v, got %v", wantLabels, alert.Labels)
+	}
+	if alert.Annotations["description"] != variableDescription("weather", "wind_gusts_10m") || alert.Annotations["value"] != "70" {
+		t.Errorf("unexpected annotations: %v", alert.Annotations)
+	}
+	if !alert.StartsAt.Equal(now) || !alert.EndsAt.Equal(now.Add(4*cfg.ResendInterval)) {
+		t.Errorf("unexpected times of firing alert: %s to %s", alert.StartsAt, alert.EndsAt)
+	}
+
+	// Resending extends the end of firing alerts.
+	now = now.Add(time.Minute)
+	if err := am.send(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := posted[1][0].EndsAt; !got.Equal(now.Add(4 * cfg.ResendInterval)) {
+		t.Errorf("resending did not extend the alert, ends at %s", got)
+	}
+
+	resolvedAt := now
+	if err := engine.Write(context.Background(), windGustsData(&config.Locations[0], 30)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := posted[2][0].EndsAt; !got.Equal(resolvedAt) {
+		t.Errorf("expected the resolved alert to end at %s, got %s", resolvedAt, got)
+	}
+
+	// Resolved alerts are dropped after a while.
+	now = now.Add(alertmanagerResolvedRetention + time.Minute)
+	if err := am.send(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posted) != 3 {
+		t.Errorf("expected no more alerts to be posted, got %+v", posted[3:])
+	}
+}
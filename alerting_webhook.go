@@ -0,0 +1,153 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/version"
+)
+
+const defaultWebhookTimeout = 10 * time.Second
+
+var alertNotificationsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alert_notifications_total",
+		Help:      "The number of notifications sent to each notifier, by result.",
+	},
+	[]string{"notifier", "result"},
+)
+
+// WebhookConfig is an HTTP endpoint notifications are posted to.
+type WebhookConfig struct {
+	// Identifies the webhook in logs and metrics, the host of the URL if
+	// empty, as the rest of the URL often holds a token.
+	Name    string            `yaml:"name,omitempty"`
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// A Go template of the request body, executed with the notification.
+	// The notification is sent as JSON if empty.
+	Template string        `yaml:"template,omitempty"`
+	Timeout  time.Duration `yaml:"timeout,omitempty"`
+}
+
+func (c *WebhookConfig) Validate() error {
+	var errs []error
+	if len(c.URL) == 0 {
+		errs = append(errs, errors.New("invalid webhook config, no url provided"))
+	} else if u, err := url.Parse(c.URL); err != nil || u.Host == "" {
+		errs = append(errs, fmt.Errorf("invalid webhook config, bad url: %s", c.URL))
+	} else if len(c.Name) == 0 {
+		c.Name = u.Host
+	}
+	if len(c.Template) != 0 {
+		if _, err := parseWebhookTemplate(c.Template); err != nil {
+			errs = append(errs, fmt.Errorf("invalid webhook config, bad template for %s: %w", c.Name, err))
+		}
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultWebhookTimeout
+	}
+
+	return errors.Join(errs...)
+}
+
+// The json function of the templates encodes a value as JSON, e.g. to embed
+// a string in a JSON payload.
+func parseWebhookTemplate(text string) (*template.Template, error) {
+	return template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+}
+
+// Webhook posts notifications to a webhook.
+type Webhook struct {
+	Config   *WebhookConfig
+	Client   *http.Client
+	template *template.Template
+}
+
+func NewWebhook(cfg *WebhookConfig) (*Webhook, error) {
+	w := &Webhook{Config: cfg, Client: &http.Client{Timeout: cfg.Timeout}}
+	if len(cfg.Template) != 0 {
+		tmpl, err := parseWebhookTemplate(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		w.template = tmpl
+	}
+	return w, nil
+}
+
+func (w *Webhook) Name() string {
+	return w.Config.Name
+}
+
+// Returns the body of the request of a notification.
+func (w *Webhook) payload(n *Notification) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(n)
+	}
+
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, n *Notification) error {
+	body, err := w.payload(n)
+	if err != nil {
+		return fmt.Errorf("failed to build payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "openmeteo_exporter/"+version.Version)
+	for name, value := range w.Config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
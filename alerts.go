@@ -0,0 +1,269 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+var ValidAlertSeverities = []string{"critical", "warning", "info"}
+
+// AlertRuleConfig overrides the defaults of one of the standard alerts of a
+// location. The threshold is in the unit the location exports the variable
+// in, e.g. °F with the default temperature_unit.
+type AlertRuleConfig struct {
+	Disabled  bool           `yaml:"disabled,omitempty"`
+	Threshold *float64       `yaml:"threshold,omitempty"`
+	For       *time.Duration `yaml:"for,omitempty"`
+	Severity  string         `yaml:"severity,omitempty"`
+}
+
+// AlertsConfig maps the keys of the standard alerts to their settings.
+// Alerts that are not listed use their defaults.
+type AlertsConfig map[string]AlertRuleConfig
+
+// alertDefinition is one of the standard alerts. It applies to every
+// location that has its variable configured.
+type alertDefinition struct {
+	// The key of the alert in the alerts section of a location.
+	Key      string
+	Alert    string
+	API      string
+	Variable string
+	// Fires when the value is at or below the threshold instead of at or
+	// above it.
+	Below bool
+	// The default threshold for each unit the variable can be exported in.
+	Thresholds map[string]float64
+	For        time.Duration
+	Severity   string
+	Summary    string
+}
+
+var alertDefinitions = []alertDefinition{
+	{
+		Key:        "extreme_heat",
+		Alert:      "OpenMeteoExtremeHeat",
+		API:        "weather",
+		Variable:   "temperature_2m",
+		Thresholds: map[string]float64{"°C": 35, "°F": 95},
+		For:        30 * time.Minute,
+		Severity:   "warning",
+		Summary:    "Extreme heat",
+	},
+	{
+		Key:        "frost",
+		Alert:      "OpenMeteoFrost",
+		API:        "weather",
+		Variable:   "temperature_2m",
+		Below:      true,
+		Thresholds: map[string]float64{"°C": 0, "°F": 32},
+		For:        30 * time.Minute,
+		Severity:   "info",
+		Summary:    "Frost",
+	},
+	{
+		Key:        "high_wind_gusts",
+		Alert:      "OpenMeteoHighWindGusts",
+		API:        "weather",
+		Variable:   "wind_gusts_10m",
+		Thresholds: map[string]float64{"km/h": 75, "mp/h": 45, "m/s": 20, "kn": 40},
+		For:        15 * time.Minute,
+		Severity:   "warning",
+		Summary:    "High wind gusts",
+	},
+	{
+		// Heavy rain as defined by the American Meteorological Society.
+		Key:        "heavy_precipitation",
+		Alert:      "OpenMeteoHeavyPrecipitation",
+		API:        "weather",
+		Variable:   "precipitation",
+		Thresholds: map[string]float64{"mm": 7.6, "inch": 0.3},
+		For:        15 * time.Minute,
+		Severity:   "warning",
+		Summary:    "Heavy precipitation",
+	},
+	{
+		Key:        "unhealthy_us_aqi",
+		Alert:      "OpenMeteoUnhealthyAirQuality",
+		API:        "airquality",
+		Variable:   "us_aqi",
+		Thresholds: map[string]float64{"USAQI": aqiBandMin(usAQIBands, "unhealthy")},
+		For:        time.Hour,
+		Severity:   "warning",
+		Summary:    "Unhealthy air quality",
+	},
+	{
+		Key:        "unhealthy_european_aqi",
+		Alert:      "OpenMeteoUnhealthyAirQuality",
+		API:        "airquality",
+		Variable:   "european_aqi",
+		Thresholds: map[string]float64{"EAQI": aqiBandMin(europeanAQIBands, "poor")},
+		For:        time.Hour,
+		Severity:   "warning",
+		Summary:    "Unhealthy air quality",
+	},
+}
+
+func lookupAlertDefinition(key string) (*alertDefinition, bool) {
+	for i := range alertDefinitions {
+		if alertDefinitions[i].Key == key {
+			return &alertDefinitions[i], true
+		}
+	}
+	return nil, false
+}
+
+// LocationAlert is a standard alert of a location with the settings of the
+// location applied.
+type LocationAlert struct {
+	Definition *alertDefinition
+	Location   string
+	MetricName string
+	Unit       string
+	Threshold  float64
+	For        time.Duration
+	Severity   string
+}
+
+// Returns the variables of the location for an API.
+func (l *LocationConfig) variables(api string) []string {
+	if api == "weather" && l.Weather != nil {
+		return l.Weather.Variables
+	}
+	if api == "airquality" && l.AirQuality != nil {
+		return l.AirQuality.Variables
+	}
+	return nil
+}
+
+func (c AlertsConfig) Validate(l *LocationConfig, location string) []error {
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(c)) {
+		rule := c[key]
+		def, ok := lookupAlertDefinition(key)
+		if !ok {
+			keys := make([]string, len(alertDefinitions))
+			for i, def := range alertDefinitions {
+				keys[i] = def.Key
+			}
+			errs = append(errs, fmt.Errorf("invalid alert, %s, must be one of %s, for location: %s", key, strings.Join(keys, ", "), location))
+			continue
+		}
+		if rule.Disabled {
+			continue
+		}
+
+		if len(rule.Severity) != 0 && !slices.Contains(ValidAlertSeverities, rule.Severity) {
+			errs = append(errs, fmt.Errorf("invalid severity, %s, for alert %s of location: %s", rule.Severity, key, location))
+		}
+		if rule.For != nil && *rule.For < 0 {
+			errs = append(errs, fmt.Errorf("invalid alert %s, for must not be negative, for location: %s", key, location))
+		}
+		if !slices.Contains(l.variables(def.API), def.Variable) {
+			errs = append(errs, fmt.Errorf("invalid alert %s, requires the %s variable %s, for location: %s", key, def.API, def.Variable, location))
+		}
+	}
+	return errs
+}
+
+// Returns the name of the metric a variable of the location is exported as,
+// and its unit. False for variables that are not in the catalogue, whose unit
+// is only known from the responses.
+func (l *LocationConfig) exportedMetric(api, variable string) (string, string, bool) {
+	v, err := LookupVariable(api, variable)
+	if err != nil {
+		return "", "", false
+	}
+	var weather *WeatherConfig
+	if api == "weather" {
+		weather = l.Weather
+	}
+	unit := v.UnitFor(weather)
+	return variableMetricName(api, variable, unit), unit, true
+}
+
+// Returns the standard alerts that apply to the location, in the order of
+// their definitions.
+func (l *LocationConfig) alerts() []LocationAlert {
+	var alerts []LocationAlert
+	for i := range alertDefinitions {
+		def := &alertDefinitions[i]
+		if !slices.Contains(l.variables(def.API), def.Variable) {
+			continue
+		}
+		rule := l.Alerts[def.Key]
+		if rule.Disabled {
+			continue
+		}
+
+		metricName, unit, ok := l.exportedMetric(def.API, def.Variable)
+		if !ok {
+			continue
+		}
+
+		alert := LocationAlert{
+			Definition: def,
+			Location:   l.Name,
+			MetricName: metricName,
+			Unit:       unit,
+			Threshold:  def.Thresholds[unit],
+			For:        def.For,
+			Severity:   def.Severity,
+		}
+		if rule.Threshold != nil {
+			alert.Threshold = *rule.Threshold
+		}
+		if rule.For != nil {
+			alert.For = *rule.For
+		}
+		if len(rule.Severity) != 0 {
+			alert.Severity = rule.Severity
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// Returns the standard alerts of the location that a condition of the
+// location duplicates, as both end up in the rule file, by condition name.
+func (l *LocationConfig) duplicatedAlerts() map[string]string {
+	duplicates := make(map[string]string)
+	for _, c := range l.Conditions {
+		below := c.Operator == "<" || c.Operator == "<="
+		above := c.Operator == ">" || c.Operator == ">="
+		for _, a := range l.alerts() {
+			def := a.Definition
+			if def.API == c.API && def.Variable == c.Variable && ((def.Below && below) || (!def.Below && above)) {
+				duplicates[c.Name] = def.Key
+			}
+		}
+	}
+	return duplicates
+}
+
+func (l *LocationConfig) warnDuplicatedAlerts() {
+	duplicates := l.duplicatedAlerts()
+	for _, name := range slices.Sorted(maps.Keys(duplicates)) {
+		level.Warn(logger).Log("msg", "Condition duplicates a standard alert, disable one of them in alerts or remove the condition", "location", l.Name, "condition", name, "alert", duplicates[name])
+	}
+}
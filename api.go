@@ -0,0 +1,193 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+type apiVariable struct {
+	// Null when the API did not return a value.
+	Value       *float64 `json:"value"`
+	Unit        string   `json:"unit"`
+	Description string   `json:"description"`
+}
+
+// The states of the conditions of a location.
+const (
+	// A response is available, it may be older than the last error.
+	apiStatusOK = "ok"
+	// The requests failed and no response is available.
+	apiStatusError = "error"
+	// Nothing was fetched for the location yet.
+	apiStatusPending = "pending"
+)
+
+// The latest response of one of the APIs for a location.
+type apiConditions struct {
+	Status          string     `json:"status"`
+	ObservationTime *time.Time `json:"observation_time"`
+	FetchedAt       *time.Time `json:"fetched_at"`
+	// Time since the observation, or since the response was fetched when
+	// the API did not report an observation time.
+	StalenessSeconds *float64               `json:"staleness_seconds"`
+	LastSuccess      *time.Time             `json:"last_success"`
+	LastError        string                 `json:"last_error,omitempty"`
+	LastErrorTime    *time.Time             `json:"last_error_time,omitempty"`
+	Variables        map[string]apiVariable `json:"variables"`
+}
+
+type apiLocation struct {
+	Name       string         `json:"name"`
+	Latitude   float64        `json:"latitude"`
+	Longitude  float64        `json:"longitude"`
+	Timezone   string         `json:"timezone"`
+	Weather    *apiConditions `json:"weather,omitempty"`
+	AirQuality *apiConditions `json:"air_quality,omitempty"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiHandler serves the latest current conditions of the configured
+// locations as JSON, from the responses cached by the collector. It never
+// queries Open-Meteo itself, so that reading the API can't add requests while
+// Open-Meteo is failing.
+type apiHandler struct {
+	collector OpenMeteoCollector
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (h *apiHandler) conditions(loc *LocationConfig, api string, names []string, resp *BaseResponse, fetchedAt, now time.Time) *apiConditions {
+	c := &apiConditions{
+		FetchedAt: timePtr(fetchedAt),
+		Variables: make(map[string]apiVariable, len(names)),
+	}
+
+	if h.collector.Status != nil {
+		if status, ok := h.collector.Status.Get(loc.Name, api); ok {
+			c.LastSuccess = timePtr(status.LastSuccess)
+			c.LastError = status.LastError
+			c.LastErrorTime = timePtr(status.LastErrorTime)
+		}
+	}
+
+	for _, name := range names {
+		desc := variableDescription(api, name)
+		variable := apiVariable{Description: desc}
+		if resp != nil {
+			variable.Unit = resp.CurrentUnits.Variables[name]
+			if value, ok := resp.Current.Variables[name]; ok && value.Valid {
+				variable.Value = &value.Number
+			}
+		}
+		c.Variables[name] = variable
+	}
+
+	if resp == nil {
+		c.Status = apiStatusPending
+		if c.LastError != "" {
+			c.Status = apiStatusError
+		}
+		return c
+	}
+	c.Status = apiStatusOK
+
+	since := fetchedAt
+	if ts, err := resp.ObservationTime(); err == nil {
+		c.ObservationTime = timePtr(ts.UTC())
+		since = ts
+	}
+	staleness := now.Sub(since).Seconds()
+	c.StalenessSeconds = &staleness
+	return c
+}
+
+func (h *apiHandler) location(loc *LocationConfig) apiLocation {
+	cached, _ := h.collector.Cache.Get(loc.Name)
+
+	result := apiLocation{
+		Name:      loc.Name,
+		Latitude:  *loc.Latitude,
+		Longitude: *loc.Longitude,
+		Timezone:  loc.Timezone,
+	}
+
+	now := time.Now()
+	if loc.Weather != nil {
+		var resp *BaseResponse
+		if cached.Weather != nil {
+			resp = &cached.Weather.BaseResponse
+		}
+		result.Weather = h.conditions(loc, "weather", loc.Weather.Variables, resp, cached.WeatherFetchedAt, now)
+	}
+	if loc.AirQuality != nil {
+		result.AirQuality = h.conditions(loc, "airquality", loc.AirQuality.Variables, cached.AirQuality, cached.AirQualityFetchedAt, now)
+	}
+
+	// The configured timezone may be auto, prefer the one resolved by the
+	// API.
+	if cached.Weather != nil && cached.Weather.Timezone != "" {
+		result.Timezone = cached.Weather.Timezone
+	} else if cached.AirQuality != nil && cached.AirQuality.Timezone != "" {
+		result.Timezone = cached.AirQuality.Timezone
+	}
+
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		level.Error(logger).Log("msg", "Failed to write API response", "err", err)
+	}
+}
+
+// ServeLocations handles /api/v1/locations.
+func (h *apiHandler) ServeLocations(w http.ResponseWriter, r *http.Request) {
+	locations := make([]apiLocation, 0, len(h.collector.Locations))
+	for i := range h.collector.Locations {
+		locations = append(locations, h.location(&h.collector.Locations[i]))
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Locations []apiLocation `json:"locations"`
+	}{locations})
+}
+
+// ServeLocation handles /api/v1/locations/{name}.
+func (h *apiHandler) ServeLocation(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	for i := range h.collector.Locations {
+		if h.collector.Locations[i].Name == name {
+			writeJSON(w, http.StatusOK, h.location(&h.collector.Locations[i]))
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown location: %s", name)})
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestAPI(t *testing.T, collector OpenMeteoCollector) *httptest.Server {
+	t.Helper()
+	api := &apiHandler{collector: collector}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/locations", api.ServeLocations)
+	mux.HandleFunc("GET /api/v1/locations/{name}", api.ServeLocation)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getJSON(t *testing.T, url string, wantStatus int, v any) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("GET %s returned %d, want %d", url, resp.StatusCode, wantStatus)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+}
+
+func TestAPILocations(t *testing.T) {
+	client, fake := newFakeClient(t)
+	nice := validLocation(t, testLocation("Nice", []string{"temperature_2m", "weather_code"}, []string{"european_aqi"}))
+	collector := OpenMeteoCollector{
+		Client:    client,
+		Locations: []LocationConfig{*nice},
+		Status:    NewStatusTracker(),
+		Cache:     NewResponseCache(),
+	}
+	server := newTestAPI(t, collector)
+	// The API serves the responses of the last scrape.
+	collector.Fetch(context.Background())
+
+	var body struct {
+		Locations []apiLocation `json:"locations"`
+	}
+	getJSON(t, server.URL+"/api/v1/locations", http.StatusOK, &body)
+	if len(body.Locations) != 1 {
+		t.Fatalf("expected 1 location, got %d", len(body.Locations))
+	}
+
+	loc := body.Locations[0]
+	if loc.Name != "Nice" || loc.Timezone == "auto" || loc.Timezone == "" {
+		t.Errorf("unexpected location %+v", loc)
+	}
+	temperature := loc.Weather.Variables["temperature_2m"]
+	if temperature.Value == nil || *temperature.Value != 65.12 || temperature.Unit != "°F" || temperature.Description == "" {
+		t.Errorf("unexpected temperature %+v", temperature)
+	}
+	if loc.Weather.ObservationTime == nil || loc.Weather.StalenessSeconds == nil || loc.Weather.LastSuccess == nil {
+		t.Errorf("observation time, staleness or last success missing: %+v", loc.Weather)
+	}
+	if aqi := loc.AirQuality.Variables["european_aqi"]; aqi.Value == nil || *aqi.Value != 34 {
+		t.Errorf("unexpected european_aqi %+v", aqi)
+	}
+
+	if loc.Weather.Status != apiStatusOK {
+		t.Errorf("expected status ok, got %s", loc.Weather.Status)
+	}
+
+	// The API never queries Open-Meteo itself.
+	requests := fake.Requests()
+	var single apiLocation
+	getJSON(t, server.URL+"/api/v1/locations/Nice", http.StatusOK, &single)
+	if single.Name != "Nice" {
+		t.Errorf("unexpected location %+v", single)
+	}
+	if n := fake.Requests(); n != requests {
+		t.Errorf("expected the cached responses to be used, got %d new requests", n-requests)
+	}
+}
+
+func TestAPIUnknownLocation(t *testing.T) {
+	client, _ := newFakeClient(t)
+	nice := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+	server := newTestAPI(t, OpenMeteoCollector{Client: client, Locations: []LocationConfig{*nice}, Cache: NewResponseCache()})
+
+	var body apiError
+	getJSON(t, server.URL+"/api/v1/locations/Atlantis", http.StatusNotFound, &body)
+	if body.Error != "unknown location: Atlantis" {
+		t.Errorf("unexpected error %q", body.Error)
+	}
+}
+
+func TestAPIDoesNotFetch(t *testing.T) {
+	client, fake := newFakeClient(t)
+	nice := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+	collector := OpenMeteoCollector{
+		Client:    client,
+		Locations: []LocationConfig{*nice},
+		Status:    NewStatusTracker(),
+		Cache:     NewResponseCache(),
+	}
+	server := newTestAPI(t, collector)
+
+	var loc apiLocation
+	getJSON(t, server.URL+"/api/v1/locations/Nice", http.StatusOK, &loc)
+	if loc.Weather.Status != apiStatusPending || loc.Weather.Variables["temperature_2m"].Value != nil {
+		t.Errorf("expected a pending location without values, got %+v", loc.Weather)
+	}
+
+	// A failed scrape is reported as an error state.
+	fake.InjectError(http.StatusInternalServerError, "Internal error", 1)
+	collector.Fetch(context.Background())
+	getJSON(t, server.URL+"/api/v1/locations/Nice", http.StatusOK, &loc)
+	if loc.Weather.Status != apiStatusError || loc.Weather.LastError == "" {
+		t.Errorf("expected an error state, got %+v", loc.Weather)
+	}
+
+	if n := fake.Requests(); n != 1 {
+		t.Errorf("expected only the request of the scrape, got %d requests", n)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import "strings"
+
+// AQIBand is a range of an air quality index, from Min up to the Min of the
+// next band.
+type AQIBand struct {
+	Min   float64
+	Label string
+	// The colour used for the band by the publisher of the index.
+	Color string
+}
+
+// The bands of the European Air Quality Index, as published by the European
+// Environment Agency.
+var europeanAQIBands = []AQIBand{
+	{0, "good", "#50f0e6"},
+	{20, "fair", "#50ccaa"},
+	{40, "moderate", "#f0e641"},
+	{60, "poor", "#ff5050"},
+	{80, "very poor", "#960032"},
+	{100, "extremely poor", "#7d2181"},
+}
+
+// The bands of the United States Air Quality Index, as published by the EPA.
+var usAQIBands = []AQIBand{
+	{0, "good", "#00e400"},
+	{51, "moderate", "#ffff00"},
+	{101, "unhealthy for sensitive groups", "#ff7e00"},
+	{151, "unhealthy", "#ff0000"},
+	{201, "very unhealthy", "#8f3f97"},
+	{301, "hazardous", "#7e0023"},
+}
+
+// Returns the bands of an air quality variable, nil if it is not an index
+// with bands.
+func aqiBands(variable string) []AQIBand {
+	switch {
+	case strings.HasPrefix(variable, "european_aqi"):
+		return europeanAQIBands
+	case strings.HasPrefix(variable, "us_aqi"):
+		return usAQIBands
+	}
+	return nil
+}
+
+// Returns the lower bound of the band with the given label.
+func aqiBandMin(bands []AQIBand, label string) float64 {
+	for _, band := range bands {
+		if band.Label == label {
+			return band.Min
+		}
+	}
+	panic("unknown AQI band: " + label)
+}
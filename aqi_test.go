@@ -0,0 +1,107 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import "testing"
+
+// Returns the label of the band a value falls in, the way Grafana applies
+// the thresholds of the bands.
+func aqiBandLabel(bands []AQIBand, value float64) string {
+	label := ""
+	for _, band := range bands {
+		if value >= band.Min {
+			label = band.Label
+		}
+	}
+	return label
+}
+
+func TestAQIBands(t *testing.T) {
+	tests := []struct {
+		variable string
+		value    float64
+		want     string
+	}{
+		{"european_aqi", 0, "good"},
+		{"european_aqi", 19.9, "good"},
+		{"european_aqi", 20, "fair"},
+		{"european_aqi", 40, "moderate"},
+		{"european_aqi", 60, "poor"},
+		{"european_aqi", 80, "very poor"},
+		{"european_aqi", 100, "extremely poor"},
+		{"european_aqi", 250, "extremely poor"},
+		{"european_aqi_pm2_5", 45, "moderate"},
+		{"us_aqi", 0, "good"},
+		{"us_aqi", 50, "good"},
+		{"us_aqi", 51, "moderate"},
+		{"us_aqi", 100, "moderate"},
+		{"us_aqi", 101, "unhealthy for sensitive groups"},
+		{"us_aqi", 151, "unhealthy"},
+		{"us_aqi", 201, "very unhealthy"},
+		{"us_aqi", 301, "hazardous"},
+		{"us_aqi", 500, "hazardous"},
+		{"us_aqi_ozone", 160, "unhealthy"},
+	}
+	for _, tt := range tests {
+		if got := aqiBandLabel(aqiBands(tt.variable), tt.value); got != tt.want {
+			t.Errorf("%s %v: got band %q, want %q", tt.variable, tt.value, got, tt.want)
+		}
+	}
+
+	for _, variable := range []string{"pm2_5", "uv_index", "ozone"} {
+		if bands := aqiBands(variable); bands != nil {
+			t.Errorf("expected no bands for %s, got %v", variable, bands)
+		}
+	}
+}
+
+func TestAQIBandsAscending(t *testing.T) {
+	for name, bands := range map[string][]AQIBand{"european": europeanAQIBands, "us": usAQIBands} {
+		if bands[0].Min != 0 {
+			t.Errorf("%s bands start at %v, want 0", name, bands[0].Min)
+		}
+		for i := 1; i < len(bands); i++ {
+			if bands[i].Min <= bands[i-1].Min {
+				t.Errorf("%s band %q does not start above %q", name, bands[i].Label, bands[i-1].Label)
+			}
+		}
+	}
+}
+
+func TestAQIBandMin(t *testing.T) {
+	tests := []struct {
+		bands []AQIBand
+		label string
+		want  float64
+	}{
+		{usAQIBands, "unhealthy", 151},
+		{usAQIBands, "hazardous", 301},
+		{europeanAQIBands, "poor", 60},
+		{europeanAQIBands, "good", 0},
+	}
+	for _, tt := range tests {
+		if got := aqiBandMin(tt.bands, tt.label); got != tt.want {
+			t.Errorf("aqiBandMin(%q) = %v, want %v", tt.label, got, tt.want)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected an unknown band to panic")
+		}
+	}()
+	aqiBandMin(usAQIBands, "excellent")
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+// ResponseSource tells whether a response was fetched from Open-Meteo or
+// served from the cache.
+type ResponseSource string
+
+const (
+	SourceLive  ResponseSource = "live"
+	SourceCache ResponseSource = "cache"
+)
+
+var responseSources = []ResponseSource{SourceCache, SourceLive}
+
+// CachedLocation holds the last successful responses of a location and
+// when they were fetched.
+type CachedLocation struct {
+	Weather             *WeatherResponse
+	WeatherFetchedAt    time.Time
+	AirQuality          *BaseResponse
+	AirQualityFetchedAt time.Time
+}
+
+// ResponseCache keeps the last successful responses of each location, so
+// that they can be served without querying Open-Meteo again.
+type ResponseCache struct {
+	// Directory the responses are persisted to, so that they survive a
+	// restart. Not persisted if empty.
+	Dir string
+	// Responses younger than MaxAge are served instead of querying
+	// Open-Meteo, and responses younger than FallbackMaxAge when the request
+	// fails. Never served if zero.
+	MaxAge         time.Duration
+	FallbackMaxAge time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]*CachedLocation
+}
+
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{entries: make(map[string]*CachedLocation)}
+}
+
+// Update stores the responses of data. A failed request does not replace
+// the response of an earlier successful one, and responses served from the
+// cache keep the time they were fetched.
+func (c *ResponseCache) Update(data *LocationData) {
+	c.mu.Lock()
+	entry, ok := c.entries[data.Location.Name]
+	if !ok {
+		entry = &CachedLocation{}
+		c.entries[data.Location.Name] = entry
+	}
+	updated := false
+	if data.Weather != nil && data.WeatherSource != SourceCache {
+		entry.Weather = data.Weather
+		entry.WeatherFetchedAt = data.FetchedAt
+		updated = true
+	}
+	if data.AirQuality != nil && data.AirQualitySource != SourceCache {
+		entry.AirQuality = data.AirQuality
+		entry.AirQualityFetchedAt = data.FetchedAt
+		updated = true
+	}
+	saved := *entry
+	c.mu.Unlock()
+
+	if updated && c.Dir != "" {
+		if err := c.save(data.Location, &saved); err != nil {
+			level.Warn(logger).Log("msg", "Failed to persist responses", "location", data.Location.Name, "dir", c.Dir, "err", err)
+		}
+	}
+}
+
+// Get returns a copy of the cached responses of a location.
+func (c *ResponseCache) Get(name string) (CachedLocation, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[name]
+	if !ok {
+		return CachedLocation{}, false
+	}
+	return *entry, true
+}
+
+// Fresh reports whether a response fetched at fetchedAt can be served
+// instead of querying Open-Meteo.
+func (c *ResponseCache) Fresh(fetchedAt, now time.Time) bool {
+	return !fetchedAt.IsZero() && now.Sub(fetchedAt) < c.MaxAge
+}
+
+// Usable reports whether a response fetched at fetchedAt can be served when
+// querying Open-Meteo failed.
+func (c *ResponseCache) Usable(fetchedAt, now time.Time) bool {
+	return !fetchedAt.IsZero() && now.Sub(fetchedAt) < c.FallbackMaxAge
+}
+
+// A response as persisted to disk. The body is kept as returned by the API
+// and decoded again when loaded, along with the query parameters of the
+// request so that responses to a different configuration are ignored.
+type persistedResponse struct {
+	Query     string          `json:"query"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	Body      json.RawMessage `json:"body"`
+}
+
+type persistedLocation struct {
+	Location   string             `json:"location"`
+	Weather    *persistedResponse `json:"weather,omitempty"`
+	AirQuality *persistedResponse `json:"air_quality,omitempty"`
+}
+
+func (c *ResponseCache) path(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func (c *ResponseCache) save(loc *LocationConfig, entry *CachedLocation) error {
+	persisted := persistedLocation{Location: loc.Name}
+	if entry.Weather != nil && loc.Weather != nil {
+		persisted.Weather = &persistedResponse{
+			Query:     weatherValues(loc).Encode(),
+			FetchedAt: entry.WeatherFetchedAt,
+			Body:      entry.Weather.Raw,
+		}
+	}
+	if entry.AirQuality != nil && loc.AirQuality != nil {
+		persisted.AirQuality = &persistedResponse{
+			Query:     airQualityValues(loc).Encode(),
+			FetchedAt: entry.AirQualityFetchedAt,
+			Body:      entry.AirQuality.Raw,
+		}
+	}
+
+	data, err := json.MarshalIndent(&persisted, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path(loc.Name), data)
+}
+
+// Load reads the persisted responses of the locations. Responses that were
+// requested with different settings than the current ones, e.g. other
+// variables, are skipped.
+func (c *ResponseCache) Load(locations []LocationConfig) error {
+	var errs []error
+	for i := range locations {
+		loc := &locations[i]
+		data, err := os.ReadFile(c.path(loc.Name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		var persisted persistedLocation
+		if err := json.Unmarshal(data, &persisted); err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse cached responses of %s: %w", loc.Name, err))
+			continue
+		}
+		if persisted.Location != loc.Name {
+			continue
+		}
+
+		entry := &CachedLocation{}
+		if r := persisted.Weather; r != nil && loc.Weather != nil && r.Query == weatherValues(loc).Encode() {
+			resp := &WeatherResponse{}
+			if err := decodeResponse("weather", r.Body, resp); err != nil {
+				errs = append(errs, fmt.Errorf("failed to decode cached weather response of %s: %w", loc.Name, err))
+			} else {
+				resp.Raw = r.Body
+				entry.Weather, entry.WeatherFetchedAt = resp, r.FetchedAt
+			}
+		}
+		if r := persisted.AirQuality; r != nil && loc.AirQuality != nil && r.Query == airQualityValues(loc).Encode() {
+			resp := &BaseResponse{}
+			if err := decodeResponse("airquality", r.Body, resp); err != nil {
+				errs = append(errs, fmt.Errorf("failed to decode cached air quality response of %s: %w", loc.Name, err))
+			} else {
+				resp.Raw = r.Body
+				entry.AirQuality, entry.AirQualityFetchedAt = resp, r.FetchedAt
+			}
+		}
+		if entry.Weather == nil && entry.AirQuality == nil {
+			continue
+		}
+
+		c.mu.Lock()
+		c.entries[loc.Name] = entry
+		c.mu.Unlock()
+		level.Info(logger).Log("msg", "Loaded cached responses", "location", loc.Name, "weather_fetched_at", entry.WeatherFetchedAt, "airquality_fetched_at", entry.AirQualityFetchedAt)
+	}
+	return errors.Join(errs...)
+}
+
+// Writes to a temporary file first so that a crash never leaves a truncated
+// file behind. Every write uses its own temporary file, so concurrent writes
+// of the same file never mix.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestResponseCachePersistence(t *testing.T) {
+	client, _ := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, []string{"european_aqi"}))
+	dir := t.TempDir()
+
+	cache := NewResponseCache()
+	cache.Dir = dir
+	collector := OpenMeteoCollector{Client: client, Locations: []LocationConfig{*loc}, Cache: cache}
+	data := collector.Fetch(context.Background())[0]
+	if data.WeatherErr != nil || data.AirQualityErr != nil {
+		t.Fatalf("unexpected errors: %v, %v", data.WeatherErr, data.AirQualityErr)
+	}
+
+	loaded := NewResponseCache()
+	loaded.Dir = dir
+	if err := loaded.Load([]LocationConfig{*loc}); err != nil {
+		t.Fatalf("failed to load cache: %v", err)
+	}
+	entry, ok := loaded.Get("Nice")
+	if !ok || entry.Weather == nil || entry.AirQuality == nil {
+		t.Fatalf("expected both responses to be loaded, got %+v", entry)
+	}
+	if !entry.WeatherFetchedAt.Equal(data.FetchedAt) {
+		t.Errorf("expected fetch time %s, got %s", data.FetchedAt, entry.WeatherFetchedAt)
+	}
+	if got, want := entry.Weather.Current.Variables["temperature_2m"], data.Weather.Current.Variables["temperature_2m"]; got != want {
+		t.Errorf("expected temperature %+v, got %+v", want, got)
+	}
+
+	// Responses to other settings are not loaded.
+	changed := *loc
+	changed.Weather = &WeatherConfig{Variables: []string{"temperature_2m"}, TemperatureUnit: "celsius"}
+	changed = *validLocation(t, changed)
+	loaded = NewResponseCache()
+	loaded.Dir = dir
+	if err := loaded.Load([]LocationConfig{changed}); err != nil {
+		t.Fatalf("failed to load cache: %v", err)
+	}
+	if entry, _ := loaded.Get("Nice"); entry.Weather != nil || entry.AirQuality == nil {
+		t.Errorf("expected only the air quality response to be loaded, got %+v", entry)
+	}
+}
+
+func TestCollectorCacheSource(t *testing.T) {
+	client, fake := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+
+	cache := NewResponseCache()
+	cache.MaxAge = time.Hour
+	cache.FallbackMaxAge = 2 * time.Hour
+	collector := OpenMeteoCollector{Client: client, Locations: []LocationConfig{*loc}, Cache: cache, Status: NewStatusTracker()}
+
+	if data := collector.Fetch(context.Background())[0]; data.WeatherSource != SourceLive {
+		t.Errorf("expected a live response, got %s", data.WeatherSource)
+	}
+	requests := fake.Requests()
+
+	// Fresh responses are served without a request.
+	data := collector.Fetch(context.Background())[0]
+	if data.WeatherSource != SourceCache || fake.Requests() != requests {
+		t.Errorf("expected the cached response without a request, got %s after %d requests", data.WeatherSource, fake.Requests()-requests)
+	}
+
+	// Older responses are only served when the request fails.
+	cache.MaxAge = 0
+	fake.InjectError(http.StatusInternalServerError, "Maintenance", 1)
+	data = collector.Fetch(context.Background())[0]
+	if data.WeatherSource != SourceCache || data.Weather == nil || data.WeatherErr == nil {
+		t.Errorf("expected the cached response along with the error, got %s, %v", data.WeatherSource, data.WeatherErr)
+	}
+
+	cache.FallbackMaxAge = 0
+	fake.InjectError(http.StatusInternalServerError, "Maintenance", 1)
+	if data := collector.Fetch(context.Background())[0]; data.Weather != nil {
+		t.Errorf("expected no response once the cached one is too old, got %s", data.WeatherSource)
+	}
+
+	families, err := gatherLocationData(collector, collector.Fetch(context.Background()))
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != "openmeteo_response_source" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "source" && (l.GetValue() == string(SourceLive)) != (m.GetGauge().GetValue() == 1) {
+					t.Errorf("unexpected value %v for source %s", m.GetGauge().GetValue(), l.GetValue())
+				}
+			}
+		}
+		return
+	}
+	t.Error("openmeteo_response_source not found")
+}
+
+func TestCollectorCacheFallbackTimestamps(t *testing.T) {
+	client, fake := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, []string{"pm2_5"}))
+
+	cache := NewResponseCache()
+	cache.FallbackMaxAge = 6 * time.Hour
+	collector := OpenMeteoCollector{Client: client, Locations: []LocationConfig{*loc}, Cache: cache, UseObservationTimestamps: true}
+
+	// Returns the timestamp of the metric with the given name, 0 when it has
+	// none.
+	timestamp := func(families []*dto.MetricFamily, name string) int64 {
+		t.Helper()
+		for _, f := range families {
+			if f.GetName() == name {
+				return f.GetMetric()[0].GetTimestampMs()
+			}
+		}
+		t.Fatalf("metric %s not found", name)
+		return 0
+	}
+
+	families, err := gatherLocationData(collector, collector.Fetch(context.Background()))
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	if got := timestamp(families, "openmeteo_weather_temperature_2m_fahrenheit"); got != 1717243200000 {
+		t.Errorf("expected the observation time of the live response, got %d", got)
+	}
+
+	// The cached weather is exported at the scrape time, as Prometheus would
+	// reject its observation time, while the live air quality keeps it.
+	fake.InjectError(http.StatusInternalServerError, "Maintenance", 1)
+	data := collector.Fetch(context.Background())
+	if data[0].WeatherSource != SourceCache || data[0].AirQualitySource != SourceLive {
+		t.Fatalf("expected cached weather and live air quality, got %s and %s", data[0].WeatherSource, data[0].AirQualitySource)
+	}
+	if families, err = gatherLocationData(collector, data); err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	if got := timestamp(families, "openmeteo_weather_temperature_2m_fahrenheit"); got != 0 {
+		t.Errorf("expected no timestamp for the cached response, got %d", got)
+	}
+	if got := timestamp(families, "openmeteo_airquality_pm2_5_ug_per_m3"); got != 1717243200000 {
+		t.Errorf("expected the observation time of the live response, got %d", got)
+	}
+	// The time of the cached values is still exported.
+	if got := metricValues(t, families, "openmeteo_weather_observation_timestamp_seconds")["Nice"]; got != 1.7172432e+09 {
+		t.Errorf("expected the observation time of the cached response, got %v", got)
+	}
+}
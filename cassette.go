@@ -0,0 +1,177 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+var ErrNoRecording = errors.New("no recorded response for request")
+
+// A single recorded request and its response. Cassettes are stored as one
+// JSON file per request so they can be inspected and edited by hand.
+type cassetteEntry struct {
+	Key         string    `json:"key"`
+	URL         string    `json:"url"`
+	StatusCode  int       `json:"status_code"`
+	ContentType string    `json:"content_type"`
+	RecordedAt  time.Time `json:"recorded_at"`
+	Body        string    `json:"body"`
+}
+
+// Parameters holding comma separated lists of variables, the order of which
+// does not change the response.
+var cassetteListParams = []string{"current", "hourly", "daily"}
+
+// Builds the key identifying a request. Only the path and query parameters
+// are used, so that a cassette recorded against one host can be replayed
+// against another, and the parameters are normalized so that reordering
+// variables in the configuration does not change the key.
+func cassetteKey(u *url.URL) string {
+	query := u.Query()
+
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			if slices.Contains(cassetteListParams, name) {
+				parts := strings.Split(value, ",")
+				slices.Sort(parts)
+				value = strings.Join(parts, ",")
+			} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+				value = strconv.FormatFloat(f, 'f', -1, 64)
+			}
+			params = append(params, name+"="+value)
+		}
+	}
+	slices.Sort(params)
+
+	return u.Path + "?" + strings.Join(params, "&")
+}
+
+func cassettePath(dir, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// recordingTransport persists every response it receives to Dir.
+type recordingTransport struct {
+	Dir  string
+	Next http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	key := cassetteKey(req.URL)
+	entry := cassetteEntry{
+		Key:         key,
+		URL:         req.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		RecordedAt:  time.Now().UTC(),
+		Body:        string(body),
+	}
+
+	if err = writeCassetteEntry(cassettePath(t.Dir, key), &entry); err != nil {
+		level.Warn(logger).Log("msg", "Failed to record response", "url", req.URL.String(), "err", err)
+	} else {
+		level.Debug(logger).Log("msg", "Recorded response", "url", req.URL.String(), "key", key)
+	}
+
+	return resp, nil
+}
+
+func writeCassetteEntry(path string, entry *cassetteEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// replayTransport serves responses previously recorded to Dir and never
+// touches the network.
+type replayTransport struct {
+	Dir string
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := cassetteKey(req.URL)
+
+	data, err := os.ReadFile(cassettePath(t.Dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoRecording, key)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entry cassetteEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid recording for %s: %w", key, err)
+	}
+
+	header := http.Header{}
+	if entry.ContentType != "" {
+		header.Set("Content-Type", entry.ContentType)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}, nil
+}
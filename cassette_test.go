@@ -0,0 +1,75 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/thelande/openmeteo_exporter/internal/fakeopenmeteo"
+)
+
+func TestCassetteKey(t *testing.T) {
+	a, _ := url.Parse("https://api.open-meteo.com/v1/forecast?longitude=7.270000&latitude=43.700000&current=wind_speed_10m,temperature_2m")
+	b, _ := url.Parse("http://127.0.0.1:8080/v1/forecast?latitude=43.7&current=temperature_2m,wind_speed_10m&longitude=7.27")
+	if ka, kb := cassetteKey(a), cassetteKey(b); ka != kb {
+		t.Errorf("keys of equivalent requests differ: %q != %q", ka, kb)
+	}
+
+	c, _ := url.Parse("https://api.open-meteo.com/v1/forecast?latitude=43.7&longitude=7.28&current=temperature_2m,wind_speed_10m")
+	if cassetteKey(a) == cassetteKey(c) {
+		t.Error("keys of different requests are equal")
+	}
+}
+
+func TestCassetteRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	client, fake := newFakeClient(t)
+	client.HTTPClient = &http.Client{Transport: &recordingTransport{Dir: dir, Next: http.DefaultTransport}}
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m", "wind_speed_10m"}, nil))
+	recorded, err := client.GetWeather(context.Background(), loc)
+	if err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+
+	// Replay against a host that doesn't exist, with the variables in
+	// another order.
+	replay := &OpenMeteoClient{
+		WeatherURL: "http://replay.invalid" + fakeopenmeteo.ForecastPath,
+		HTTPClient: &http.Client{Transport: &replayTransport{Dir: dir}},
+	}
+	loc.Weather.Variables = []string{"wind_speed_10m", "temperature_2m"}
+	replayed, err := replay.GetWeather(context.Background(), loc)
+	if err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	if !reflect.DeepEqual(replayed.Current, recorded.Current) {
+		t.Errorf("replayed values %v differ from the recorded ones %v", replayed.Current, recorded.Current)
+	}
+	if n := fake.Requests(); n != 1 {
+		t.Errorf("expected only the recorded request, got %d requests", n)
+	}
+
+	loc.Weather.Variables = []string{"relative_humidity_2m"}
+	if _, err := replay.GetWeather(context.Background(), loc); !errors.Is(err, ErrNoRecording) {
+		t.Errorf("expected ErrNoRecording for a request that was not recorded, got %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/thelande/openmeteo_exporter/internal/fakeopenmeteo"
+)
+
+// Validates the location to apply the defaults, as the configuration does.
+func validLocation(t *testing.T, loc LocationConfig) *LocationConfig {
+	t.Helper()
+	if err := loc.Validate(false); err != nil {
+		t.Fatalf("invalid location: %v", err)
+	}
+	return &loc
+}
+
+func TestClientInjectedError(t *testing.T) {
+	client, fake := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+
+	fake.InjectError(http.StatusBadRequest, "Latitude must be in range of -90 to 90°.", 1)
+	_, err := client.GetWeather(context.Background(), loc)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Reason != "Latitude must be in range of -90 to 90°." {
+		t.Errorf("unexpected error: %v", apiErr)
+	}
+	if !errors.Is(err, ErrNon2XXResponse) {
+		t.Errorf("expected the error to match ErrNon2XXResponse: %v", err)
+	}
+
+	// Only the next request fails.
+	if _, err := client.GetWeather(context.Background(), loc); err != nil {
+		t.Errorf("unexpected error after the injected one: %v", err)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	client, fake := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", nil, []string{"pm2_5"}))
+
+	fake.SetRateLimit(1)
+	if _, err := client.GetAirQuality(context.Background(), loc); err != nil {
+		t.Fatalf("unexpected error for the first request: %v", err)
+	}
+	_, err := client.GetAirQuality(context.Background(), loc)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected a 429 APIError, got %v", err)
+	}
+	if n := fake.Requests(); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestClientLatency(t *testing.T) {
+	client, fake := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+
+	fake.SetLatency(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.GetWeather(ctx, loc)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the request to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("request was not cancelled in time, took %s", elapsed)
+	}
+	if class := errorReasonClass(err); class != "timeout" {
+		t.Errorf("expected reason class timeout, got %s", class)
+	}
+}
+
+func TestClientCoalescesIdenticalRequests(t *testing.T) {
+	client, fake := newFakeClient(t)
+
+	team := testLocation("Harbour Team", []string{"temperature_2m", "wind_gusts_10m"}, nil)
+	site := testLocation("Harbour Site", []string{"wind_gusts_10m", "temperature_2m"}, nil)
+	metric := testLocation("Harbour Metric", []string{"temperature_2m", "wind_gusts_10m"}, nil)
+	metric.Weather.TemperatureUnit = "celsius"
+	config := Config{Locations: []LocationConfig{team, site, metric}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+
+	before := testutil.ToFloat64(requestsDeduplicatedTotal.WithLabelValues("weather"))
+	collector := OpenMeteoCollector{Client: client, Locations: config.Locations}
+	for _, data := range collector.Fetch(context.Background()) {
+		if data.WeatherErr != nil {
+			t.Fatalf("unexpected error for %s: %v", data.Location.Name, data.WeatherErr)
+		}
+	}
+
+	// The variables of the first two are in a different order, but the
+	// requests are the same. The third one uses other units.
+	if got := fake.Requests(); got != 2 {
+		t.Errorf("expected 2 requests, got %d", got)
+	}
+	if got := testutil.ToFloat64(requestsDeduplicatedTotal.WithLabelValues("weather")) - before; got != 1 {
+		t.Errorf("expected 1 deduplicated request, got %v", got)
+	}
+
+	// Responses are only shared within a poll.
+	collector.Fetch(context.Background())
+	if got := fake.Requests(); got != 4 {
+		t.Errorf("expected 4 requests after the second poll, got %d", got)
+	}
+}
+
+func TestClientSharedRequestOutlivesCaller(t *testing.T) {
+	client, fake := newFakeClient(t)
+	fake.SetLatency(200 * time.Millisecond)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+
+	before := testutil.ToFloat64(requestsDeduplicatedTotal.WithLabelValues("weather"))
+	poll := withRequestGroup(context.Background())
+	ctx, cancel := context.WithCancel(poll)
+	first := make(chan error, 1)
+	go func() {
+		_, err := client.GetWeather(ctx, loc)
+		first <- err
+	}()
+	for fake.Requests() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := client.GetWeather(poll, loc)
+		second <- err
+	}()
+	// Give the second caller time to join the request in flight.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the first caller to be cancelled, got %v", err)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("expected the second caller to get the response, got %v", err)
+	}
+	if got := fake.Requests(); got != 1 {
+		t.Errorf("expected 1 request, got %d", got)
+	}
+	if got := testutil.ToFloat64(requestsDeduplicatedTotal.WithLabelValues("weather")) - before; got != 1 {
+		t.Errorf("expected 1 deduplicated request, got %v", got)
+	}
+}
+
+func TestGeocoderResolveFake(t *testing.T) {
+	client, fake := newFakeClient(t)
+	geocoder := &Geocoder{Client: client}
+
+	for i := 0; i < 2; i++ {
+		loc := LocationConfig{Name: "Home", Query: "Nice, France"}
+		if err := geocoder.Resolve(context.Background(), &loc); err != nil {
+			t.Fatalf("failed to resolve: %v", err)
+		}
+		if loc.Geocoded == nil || loc.Geocoded.CountryCode != "FR" {
+			t.Fatalf("resolved to the wrong place: %+v", loc.Geocoded)
+		}
+	}
+	if n := fake.Requests(); n != 1 {
+		t.Errorf("expected the second lookup to be cached, got %d requests", n)
+	}
+}
+
+// Records the error of each request sent with the transport.
+type errorRecordingTransport struct {
+	errs chan error
+}
+
+func (t errorRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	t.errs <- err
+	return resp, err
+}
+
+func TestClientCancelsAbandonedRequest(t *testing.T) {
+	client, fake := newFakeClient(t)
+	fake.SetLatency(time.Second)
+	transport := errorRecordingTransport{errs: make(chan error, 1)}
+	client.HTTPClient = &http.Client{Transport: transport}
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+
+	ctx, cancel := context.WithTimeout(withRequestGroup(context.Background()), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetWeather(ctx, loc); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the caller to time out, got %v", err)
+	}
+
+	// The only caller gave up, so the request to the API is cancelled
+	// rather than left running.
+	select {
+	case err := <-transport.errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the request to be cancelled, got %v", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Error("the request was not cancelled")
+	}
+}
+
+// Panics on the requests to the Air Quality API.
+type panickingTransport struct{}
+
+func (panickingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == fakeopenmeteo.AirQualityPath {
+		panic("unexpected response")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientSharedRequestPanics(t *testing.T) {
+	client, _ := newFakeClient(t)
+	client.HTTPClient = &http.Client{Transport: panickingTransport{}}
+	loc := validLocation(t, testLocation("Nice", nil, []string{"pm2_5"}))
+
+	// The panic of the shared request is raised in the caller, where the
+	// collector recovers from it.
+	defer func() {
+		if r := recover(); r != "unexpected response" {
+			t.Errorf("expected the panic of the request, got %v", r)
+		}
+	}()
+	client.GetAirQuality(withRequestGroup(context.Background()), loc) //nolint:errcheck
+}
+
+func TestGeocoderResolveTimeout(t *testing.T) {
+	client, fake := newFakeClient(t)
+	fake.SetLatency(time.Second)
+	geocoder := &Geocoder{Client: client, Timeout: 20 * time.Millisecond}
+
+	// A stalled Geocoding API fails the lookup instead of hanging it.
+	start := time.Now()
+	loc := LocationConfig{Name: "Home", Query: "Nice, France"}
+	if err := geocoder.Resolve(context.Background(), &loc); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the lookup to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("the lookup took %v", elapsed)
+	}
+	if loc.Latitude != nil || loc.Geocoded != nil {
+		t.Errorf("unexpected resolution %+v", loc)
+	}
+
+	// The context of the caller bounds the lookup as well.
+	geocoder.Timeout = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := geocoder.Resolve(ctx, &loc); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the lookup to time out, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redactedSecret = "<secret>"
+
+// Validates the configuration, resolving locations given by name, and
+// prints it with all defaults applied.
+func runCheckConfig() error {
+	client, closeClient, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	config, err := loadConfig(client)
+	if err != nil {
+		return err
+	}
+
+	printable, err := printableConfig(config)
+	if err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err = enc.Encode(printable); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Returns the configuration to print, redacted. Locations given by name are
+// printed with their query, so that the output can be loaded again, and the
+// place and coordinates it resolved to in a comment.
+func printableConfig(c *Config) (*yaml.Node, error) {
+	out := redactConfig(c)
+	out.Locations = slices.Clone(c.Locations)
+	for i := range out.Locations {
+		if out.Locations[i].Query != "" {
+			out.Locations[i].Latitude = nil
+			out.Locations[i].Longitude = nil
+		}
+	}
+
+	var doc yaml.Node
+	if err := doc.Encode(out); err != nil {
+		return nil, err
+	}
+
+	locations := mappingValue(&doc, "locations")
+	for i, loc := range c.Locations {
+		if locations == nil || loc.Query == "" || loc.Latitude == nil || loc.Longitude == nil {
+			continue
+		}
+		comment := fmt.Sprintf("resolved to latitude %v, longitude %v", *loc.Latitude, *loc.Longitude)
+		if loc.Geocoded != nil {
+			comment = fmt.Sprintf("resolved to %s, %s, latitude %v, longitude %v", loc.Geocoded.Name, loc.Geocoded.Country, *loc.Latitude, *loc.Longitude)
+		}
+		if query := mappingValue(locations.Content[i], "query"); query != nil {
+			query.LineComment = comment
+		}
+	}
+	return &doc, nil
+}
+
+// Returns the value of a key of a YAML mapping, nil if it's missing.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedSecret
+}
+
+// Redacts the password of a URL, if any. Addresses that are not URLs, e.g.
+// the host:port of a gRPC endpoint, are kept.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// Redacts everything but the scheme and host of a webhook URL, as the path
+// or query of webhooks often hold a token.
+func redactWebhookURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return redact(rawURL)
+	}
+	redacted := u.Scheme + "://" + u.Host
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.User != nil {
+		redacted += "/" + redactedSecret
+	}
+	return redacted
+}
+
+// Returns a copy of the configuration that is safe to print, without the
+// passwords and tokens of the outputs and notifiers, including those in
+// URLs, and the headers of the webhooks.
+func redactConfig(c *Config) *Config {
+	out := *c
+	if c.InfluxDB != nil {
+		influxDB := *c.InfluxDB
+		influxDB.URL = redactURL(influxDB.URL)
+		influxDB.Token = redact(influxDB.Token)
+		out.InfluxDB = &influxDB
+	}
+	if c.RemoteWrite != nil {
+		remoteWrite := *c.RemoteWrite
+		remoteWrite.URL = redactURL(remoteWrite.URL)
+		remoteWrite.BearerToken = redact(remoteWrite.BearerToken)
+		if remoteWrite.BasicAuth != nil {
+			basicAuth := *remoteWrite.BasicAuth
+			basicAuth.Password = redact(basicAuth.Password)
+			remoteWrite.BasicAuth = &basicAuth
+		}
+		out.RemoteWrite = &remoteWrite
+	}
+	if c.MQTT != nil {
+		mqtt := *c.MQTT
+		mqtt.Broker = redactURL(mqtt.Broker)
+		mqtt.Password = redact(mqtt.Password)
+		out.MQTT = &mqtt
+	}
+	if c.OTLP != nil {
+		// Headers usually carry API keys.
+		otlp := *c.OTLP
+		otlp.Endpoint = redactURL(otlp.Endpoint)
+		otlp.Headers = maps.Clone(otlp.Headers)
+		for name, value := range otlp.Headers {
+			otlp.Headers[name] = redact(value)
+		}
+		out.OTLP = &otlp
+	}
+	if c.Alerting != nil {
+		alerting := *c.Alerting
+		alerting.Webhooks = make([]WebhookConfig, len(c.Alerting.Webhooks))
+		for i, webhook := range c.Alerting.Webhooks {
+			webhook.URL = redactWebhookURL(webhook.URL)
+			webhook.Headers = maps.Clone(webhook.Headers)
+			for name, value := range webhook.Headers {
+				webhook.Headers[name] = redact(value)
+			}
+			alerting.Webhooks[i] = webhook
+		}
+		alerting.Alertmanagers = make([]AlertmanagerConfig, len(c.Alerting.Alertmanagers))
+		for i, am := range c.Alerting.Alertmanagers {
+			am.URL = redactURL(am.URL)
+			am.BearerToken = redact(am.BearerToken)
+			alerting.Alertmanagers[i] = am
+		}
+		out.Alerting = &alerting
+	}
+	return &out
+}
@@ -0,0 +1,346 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/go-kit/log/level"
+)
+
+const (
+	grafanaSchemaVersion = 39
+	grafanaPanelWidth    = 12
+	grafanaPanelHeight   = 8
+)
+
+// Units of the APIs mapped to the units of Grafana. Other units are shown
+// as a suffix.
+var grafanaUnits = map[string]string{
+	"°C":    "celsius",
+	"°F":    "fahrenheit",
+	"°":     "degree",
+	"%":     "percent",
+	"km/h":  "velocitykmh",
+	"mp/h":  "velocitymph",
+	"m/s":   "velocityms",
+	"kn":    "velocityknot",
+	"hPa":   "pressurehpa",
+	"kPa":   "pressurekpa",
+	"mm":    "lengthmm",
+	"m":     "lengthm",
+	"s":     "s",
+	"μg/m³": "conμgm3",
+	"µg/m³": "conμgm3",
+}
+
+func grafanaUnit(v Variable, unit string) string {
+	if unit == "" || v.ValueType == ValueTypeIndex || v.ValueType == ValueTypeCode || v.ValueType == ValueTypeFlag {
+		return "none"
+	}
+	if u, ok := grafanaUnits[unit]; ok {
+		return u
+	}
+	return "suffix: " + unit
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// All panels and template variables use the data source selected with the
+// datasource template variable.
+var grafanaPrometheus = &grafanaDatasource{Type: "prometheus", UID: "${datasource}"}
+
+type grafanaDashboard struct {
+	UID           string   `json:"uid"`
+	Title         string   `json:"title"`
+	Tags          []string `json:"tags"`
+	Timezone      string   `json:"timezone"`
+	SchemaVersion int      `json:"schemaVersion"`
+	Editable      bool     `json:"editable"`
+	Refresh       string   `json:"refresh"`
+	Time          struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"time"`
+	Templating struct {
+		List []grafanaTemplateVariable `json:"list"`
+	} `json:"templating"`
+	Panels []grafanaPanel `json:"panels"`
+}
+
+type grafanaTemplateVariable struct {
+	Name       string             `json:"name"`
+	Label      string             `json:"label"`
+	Type       string             `json:"type"`
+	Query      string             `json:"query"`
+	Datasource *grafanaDatasource `json:"datasource,omitempty"`
+	Refresh    int                `json:"refresh,omitempty"`
+	Sort       int                `json:"sort,omitempty"`
+	Multi      bool               `json:"multi,omitempty"`
+	IncludeAll bool               `json:"includeAll,omitempty"`
+}
+
+type grafanaGridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type grafanaTarget struct {
+	Datasource   *grafanaDatasource `json:"datasource"`
+	Expr         string             `json:"expr"`
+	LegendFormat string             `json:"legendFormat"`
+	RefID        string             `json:"refId"`
+}
+
+type grafanaThresholdStep struct {
+	Color string `json:"color"`
+	// Nil for the base step, which has no lower bound.
+	Value *float64 `json:"value"`
+}
+
+type grafanaFieldDefaults struct {
+	Unit       string             `json:"unit"`
+	Color      map[string]any     `json:"color,omitempty"`
+	Thresholds *grafanaThresholds `json:"thresholds,omitempty"`
+	Custom     map[string]any     `json:"custom,omitempty"`
+}
+
+type grafanaThresholds struct {
+	Mode  string                 `json:"mode"`
+	Steps []grafanaThresholdStep `json:"steps"`
+}
+
+type grafanaFieldConfig struct {
+	Defaults  grafanaFieldDefaults `json:"defaults"`
+	Overrides []any                `json:"overrides"`
+}
+
+type grafanaPanel struct {
+	ID          int                 `json:"id"`
+	Type        string              `json:"type"`
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	GridPos     grafanaGridPos      `json:"gridPos"`
+	Datasource  *grafanaDatasource  `json:"datasource,omitempty"`
+	Targets     []grafanaTarget     `json:"targets,omitempty"`
+	FieldConfig *grafanaFieldConfig `json:"fieldConfig,omitempty"`
+	Options     map[string]any      `json:"options,omitempty"`
+}
+
+// A metric exported for a variable by at least one of the locations.
+type dashboardMetric struct {
+	API        string
+	Variable   Variable
+	Unit       string
+	MetricName string
+}
+
+// Returns the metrics exported for the variables of the configured
+// locations, in the order they are first configured. A variable has more
+// than one metric if the locations use different unit settings.
+func dashboardMetrics(config *Config) []dashboardMetric {
+	var metrics []dashboardMetric
+	seen := make(map[string]bool)
+
+	add := func(loc *LocationConfig, api string, names []string, weather *WeatherConfig) {
+		for _, name := range names {
+			v, err := LookupVariable(api, name)
+			if err != nil {
+				// The name of the metric depends on the unit returned by the API.
+				level.Warn(logger).Log("msg", "Skipping variable that is not in the catalogue", "location", loc.Name, "api", api, "variable", name)
+				continue
+			}
+
+			unit := v.UnitFor(weather)
+			metricName := variableMetricName(api, name, unit)
+			if seen[metricName] {
+				continue
+			}
+			seen[metricName] = true
+			metrics = append(metrics, dashboardMetric{API: api, Variable: v, Unit: unit, MetricName: metricName})
+		}
+	}
+
+	for _, api := range []string{"weather", "airquality"} {
+		for i := range config.Locations {
+			loc := &config.Locations[i]
+			if api == "weather" && loc.Weather != nil {
+				add(loc, api, loc.Weather.Variables, loc.Weather)
+			}
+			if api == "airquality" && loc.AirQuality != nil {
+				add(loc, api, loc.AirQuality.Variables, nil)
+			}
+		}
+	}
+	return metrics
+}
+
+// Builds the panel of a metric. Codes and flags are shown as their latest
+// value, everything else as a time series, with the bands of air quality
+// indices as coloured areas.
+func dashboardPanel(m dashboardMetric) grafanaPanel {
+	title := variableTitle(m.Variable.Name)
+	if unit := grafanaUnit(m.Variable, m.Unit); unit != "none" {
+		title += " (" + m.Unit + ")"
+	}
+
+	panel := grafanaPanel{
+		Type:        "timeseries",
+		Title:       title,
+		Description: m.Variable.Description,
+		Datasource:  grafanaPrometheus,
+		Targets: []grafanaTarget{{
+			Datasource:   grafanaPrometheus,
+			Expr:         m.MetricName + `{location=~"$location"}`,
+			LegendFormat: "{{location}}",
+			RefID:        "A",
+		}},
+	}
+	panel.FieldConfig = &grafanaFieldConfig{
+		Defaults:  grafanaFieldDefaults{Unit: grafanaUnit(m.Variable, m.Unit)},
+		Overrides: []any{},
+	}
+
+	switch m.Variable.ValueType {
+	case ValueTypeCode, ValueTypeFlag:
+		panel.Type = "stat"
+		panel.Options = map[string]any{
+			"reduceOptions": map[string]any{"calcs": []string{"lastNotNull"}},
+			"graphMode":     "none",
+			"textMode":      "value_and_name",
+		}
+	case ValueTypeDirection:
+		// Angles jump between 0 and 360, which is clearer as points.
+		panel.FieldConfig.Defaults.Custom = map[string]any{"drawStyle": "points"}
+	}
+
+	if bands := aqiBands(m.Variable.Name); m.API == "airquality" && bands != nil {
+		defaults := &panel.FieldConfig.Defaults
+		defaults.Color = map[string]any{"mode": "thresholds"}
+		defaults.Custom = map[string]any{"thresholdsStyle": map[string]any{"mode": "area"}}
+		defaults.Thresholds = &grafanaThresholds{Mode: "absolute"}
+		for i, band := range bands {
+			step := grafanaThresholdStep{Color: band.Color}
+			if i > 0 {
+				step.Value = &bands[i].Min
+			}
+			defaults.Thresholds.Steps = append(defaults.Thresholds.Steps, step)
+		}
+	}
+	return panel
+}
+
+// Builds a Grafana dashboard with a panel for each variable of the
+// configured locations, with a row per API.
+func buildDashboard(config *Config, title, uid string) *grafanaDashboard {
+	d := &grafanaDashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          []string{"openmeteo"},
+		Timezone:      "browser",
+		SchemaVersion: grafanaSchemaVersion,
+		Editable:      true,
+		Refresh:       "5m",
+		Panels:        []grafanaPanel{},
+	}
+	d.Time.From = "now-24h"
+	d.Time.To = "now"
+	d.Templating.List = []grafanaTemplateVariable{
+		{
+			Name:  "datasource",
+			Label: "Data source",
+			Type:  "datasource",
+			Query: "prometheus",
+		},
+		{
+			Name:       "location",
+			Label:      "Location",
+			Type:       "query",
+			Query:      "label_values(openmeteo_location_info, location)",
+			Datasource: grafanaPrometheus,
+			Refresh:    2,
+			Sort:       1,
+			Multi:      true,
+			IncludeAll: true,
+		},
+	}
+
+	metrics := dashboardMetrics(config)
+	y := 0
+	for _, api := range []string{"weather", "airquality"} {
+		var panels []grafanaPanel
+		for _, m := range metrics {
+			if m.API == api {
+				panels = append(panels, dashboardPanel(m))
+			}
+		}
+		if len(panels) == 0 {
+			continue
+		}
+
+		rowTitle := "Weather"
+		if api == "airquality" {
+			rowTitle = "Air Quality"
+		}
+		d.Panels = append(d.Panels, grafanaPanel{
+			Type:    "row",
+			Title:   rowTitle,
+			GridPos: grafanaGridPos{X: 0, Y: y, W: 2 * grafanaPanelWidth, H: 1},
+		})
+		y++
+
+		for i, panel := range panels {
+			panel.GridPos = grafanaGridPos{
+				X: (i % 2) * grafanaPanelWidth,
+				Y: y + (i/2)*grafanaPanelHeight,
+				W: grafanaPanelWidth,
+				H: grafanaPanelHeight,
+			}
+			d.Panels = append(d.Panels, panel)
+		}
+		y += (len(panels) + 1) / 2 * grafanaPanelHeight
+	}
+
+	for i := range d.Panels {
+		d.Panels[i].ID = i + 1
+	}
+	return d
+}
+
+// Prints a Grafana dashboard for the locations of the configuration.
+func runDashboard(title, uid string) error {
+	client, closeClient, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	config, err := loadConfig(client)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(buildDashboard(config, title, uid))
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBuildDashboard(t *testing.T) {
+	config := parseConfig(t, `
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    weather:
+      temperature_unit: celsius
+      variables: [temperature_2m, weather_code, wind_direction_10m]
+    air_quality:
+      variables: [us_aqi, pm2_5]
+  - name: Denver
+    latitude: 39.7
+    longitude: -105
+    weather:
+      variables: [temperature_2m, weather_code]
+`)
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The dashboard must survive a round trip through JSON, as printed.
+	b, err := json.Marshal(buildDashboard(config, "Weather", "openmeteo"))
+	if err != nil {
+		t.Fatalf("failed to encode dashboard: %v", err)
+	}
+	var d grafanaDashboard
+	if err := json.Unmarshal(b, &d); err != nil {
+		t.Fatalf("failed to decode dashboard: %v", err)
+	}
+
+	if d.UID != "openmeteo" || d.Title != "Weather" || d.SchemaVersion != grafanaSchemaVersion {
+		t.Errorf("unexpected dashboard %s %q, schema %d", d.UID, d.Title, d.SchemaVersion)
+	}
+	if len(d.Templating.List) != 2 || d.Templating.List[1].Query != "label_values(openmeteo_location_info, location)" {
+		t.Errorf("unexpected template variables %+v", d.Templating.List)
+	}
+
+	type panel struct {
+		typ, title, expr, unit string
+		gridPos                grafanaGridPos
+	}
+	// The temperature has a metric per unit setting, and the panels of each
+	// API follow their row, two per line.
+	want := []panel{
+		{"row", "Weather", "", "", grafanaGridPos{0, 0, 24, 1}},
+		{"timeseries", "Temperature 2m (°C)", `openmeteo_weather_temperature_2m_celsius{location=~"$location"}`, "celsius", grafanaGridPos{0, 1, 12, 8}},
+		{"stat", "Weather code", `openmeteo_weather_weather_code{location=~"$location"}`, "none", grafanaGridPos{12, 1, 12, 8}},
+		{"timeseries", "Wind direction 10m (°)", `openmeteo_weather_wind_direction_10m_degrees{location=~"$location"}`, "degree", grafanaGridPos{0, 9, 12, 8}},
+		{"timeseries", "Temperature 2m (°F)", `openmeteo_weather_temperature_2m_fahrenheit{location=~"$location"}`, "fahrenheit", grafanaGridPos{12, 9, 12, 8}},
+		{"row", "Air Quality", "", "", grafanaGridPos{0, 17, 24, 1}},
+		{"timeseries", "US AQI", `openmeteo_airquality_us_aqi_usaqi{location=~"$location"}`, "none", grafanaGridPos{0, 18, 12, 8}},
+		{"timeseries", "PM2.5 (μg/m³)", `openmeteo_airquality_pm2_5_ug_per_m3{location=~"$location"}`, "conμgm3", grafanaGridPos{12, 18, 12, 8}},
+	}
+	if len(d.Panels) != len(want) {
+		t.Fatalf("expected %d panels, got %d", len(want), len(d.Panels))
+	}
+	for i, w := range want {
+		p := d.Panels[i]
+		if p.ID != i+1 {
+			t.Errorf("panel %d has id %d", i, p.ID)
+		}
+		var expr, unit string
+		if len(p.Targets) == 1 {
+			expr = p.Targets[0].Expr
+		}
+		if p.FieldConfig != nil {
+			unit = p.FieldConfig.Defaults.Unit
+		}
+		got := panel{p.Type, p.Title, expr, unit, p.GridPos}
+		if got != w {
+			t.Errorf("panel %d: got %+v, want %+v", i, got, w)
+		}
+	}
+
+	// The bands of the index are shown as thresholds, the first one without
+	// a lower bound.
+	thresholds := d.Panels[6].FieldConfig.Defaults.Thresholds
+	if thresholds == nil || len(thresholds.Steps) != len(usAQIBands) {
+		t.Fatalf("expected a threshold per band, got %+v", thresholds)
+	}
+	for i, step := range thresholds.Steps {
+		band := usAQIBands[i]
+		if step.Color != band.Color || (i == 0) != (step.Value == nil) || (i > 0 && *step.Value != band.Min) {
+			t.Errorf("step %d: got %+v, want %+v", i, step, band)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/prometheus/common/expfmt"
+)
+
+const queryTimeout = 30 * time.Second
+
+// Fetches the current conditions of a configured location once and prints
+// them.
+func runQuery(name, output string) error {
+	client, closeClient, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	config, err := loadConfig(client)
+	if err != nil {
+		return err
+	}
+
+	var loc *LocationConfig
+	for i := range config.Locations {
+		if config.Locations[i].Name == name {
+			loc = &config.Locations[i]
+		}
+	}
+	if loc == nil {
+		return fmt.Errorf("unknown location: %s", name)
+	}
+
+	collector := OpenMeteoCollector{
+		Client:    client,
+		Locations: []LocationConfig{*loc},
+		Status:    NewStatusTracker(),
+		Cache:     NewResponseCache(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	return writeQuery(ctx, os.Stdout, collector, loc, output)
+}
+
+// Fetches the current conditions of a location and writes them to w in the
+// given output format. Fails if any of the requests failed.
+func writeQuery(ctx context.Context, w io.Writer, collector OpenMeteoCollector, loc *LocationConfig, output string) error {
+	data := collector.fetchLocation(ctx, loc)
+
+	switch output {
+	case "json":
+		api := &apiHandler{collector: collector}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(api.location(loc)); err != nil {
+			return err
+		}
+	case "prometheus":
+		families, err := gatherLocationData(collector, []*LocationData{data})
+		if err != nil {
+			return err
+		}
+		for _, family := range families {
+			if _, err = expfmt.MetricFamilyToText(w, family); err != nil {
+				return err
+			}
+		}
+	default:
+		table := tablewriter.NewWriter(w)
+		table.SetHeader([]string{"API", "Variable", "Value", "Unit", "Observed"})
+		for _, sample := range data.Samples() {
+			var observed string
+			if !sample.Time.IsZero() {
+				observed = sample.Time.Format(time.RFC3339)
+			}
+			table.Append([]string{
+				sample.API,
+				sample.Variable,
+				strconv.FormatFloat(sample.Value, 'f', -1, 64),
+				sample.Unit,
+				observed,
+			})
+		}
+		table.Render()
+	}
+
+	return errors.Join(data.WeatherErr, data.AirQualityErr)
+}
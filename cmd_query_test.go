@@ -0,0 +1,85 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriteQuery(t *testing.T) {
+	client, _ := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, []string{"pm2_5"}))
+	collector := OpenMeteoCollector{
+		Client:    client,
+		Locations: []LocationConfig{*loc},
+		Status:    NewStatusTracker(),
+		Cache:     NewResponseCache(),
+	}
+
+	tests := []struct {
+		output string
+		want   []string
+	}{
+		{"table", []string{"weather", "temperature_2m", "65.12", "°F", "airquality", "pm2_5", "8.3", "2024-06-01T14:00:00+02:00"}},
+		{"prometheus", []string{
+			`openmeteo_weather_temperature_2m_fahrenheit{location="Nice"} 65.12`,
+			`openmeteo_airquality_pm2_5_ug_per_m3{location="Nice"} 8.3`,
+		}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeQuery(context.Background(), &buf, collector, loc, tt.output); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.output, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s: %q is missing:\n%s", tt.output, want, buf.String())
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeQuery(context.Background(), &buf, collector, loc, "json"); err != nil {
+		t.Fatalf("json: unexpected error: %v", err)
+	}
+	var result apiLocation
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("json: invalid output: %v", err)
+	}
+	if v := result.Weather.Variables["temperature_2m"].Value; result.Weather.Status != apiStatusOK || v == nil || *v != 65.12 {
+		t.Errorf("json: unexpected weather %+v", result.Weather)
+	}
+}
+
+func TestWriteQueryError(t *testing.T) {
+	client, fake := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+	collector := OpenMeteoCollector{Client: client, Locations: []LocationConfig{*loc}, Cache: NewResponseCache()}
+
+	fake.InjectError(http.StatusBadRequest, "Cannot initialize WeatherVariable from invalid String value", 1)
+	var buf bytes.Buffer
+	err := writeQuery(context.Background(), &buf, collector, loc, "table")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected the API error, got %v", err)
+	}
+}
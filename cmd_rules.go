@@ -0,0 +1,205 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+const apiErrorsRateRecord = "location_api:openmeteo_api_errors:rate15m"
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Formats a duration the way Prometheus does, e.g. 1h instead of 1h0m0s.
+// Zero durations are left out of the rule.
+func ruleDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return model.Duration(d).String()
+}
+
+func formatThreshold(threshold float64, unit string) string {
+	s := strconv.FormatFloat(threshold, 'f', -1, 64)
+	if unit != "" {
+		s += " " + unit
+	}
+	return s
+}
+
+// Builds the alerting rule of a standard alert of a location.
+func locationAlertRule(a LocationAlert) rule {
+	def := a.Definition
+	op, direction := ">=", "above"
+	if def.Below {
+		op, direction = "<=", "below"
+	}
+
+	threshold := strconv.FormatFloat(a.Threshold, 'f', -1, 64)
+	return rule{
+		Alert: def.Alert,
+		Expr:  fmt.Sprintf("%s{location=%q} %s %s", a.MetricName, a.Location, op, threshold),
+		For:   ruleDuration(a.For),
+		Labels: map[string]string{
+			"severity": a.Severity,
+			"variable": def.Variable,
+		},
+		Annotations: map[string]string{
+			"summary": fmt.Sprintf("%s in %s", def.Summary, a.Location),
+			"description": fmt.Sprintf("%s is {{ $value }}, at or %s the threshold of %s.",
+				variableTitle(def.Variable), direction, formatThreshold(a.Threshold, a.Unit)),
+		},
+	}
+}
+
+// Builds the alerting rule of a condition of a location, with the labels the
+// exporter sets on the alerts of the condition. False for conditions on
+// variables that are not in the catalogue.
+func conditionRule(l *LocationConfig, c *ConditionConfig) (rule, bool) {
+	metricName, unit, ok := l.exportedMetric(c.API, c.Variable)
+	if !ok {
+		return rule{}, false
+	}
+
+	labels := maps.Clone(l.Labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	maps.Copy(labels, c.Labels)
+	labels["severity"] = c.Severity
+
+	threshold := strconv.FormatFloat(c.Threshold, 'f', -1, 64)
+	return rule{
+		Alert:  c.Name,
+		Expr:   fmt.Sprintf("%s{location=%q} %s %s", metricName, l.Name, c.Operator, threshold),
+		For:    ruleDuration(c.For),
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary": fmt.Sprintf("%s in %s", c.Name, l.Name),
+			"description": fmt.Sprintf("%s is {{ $value }}, the condition is %s %s.",
+				variableTitle(c.Variable), c.Operator, formatThreshold(c.Threshold, unit)),
+		},
+	}, true
+}
+
+// Builds a Prometheus rule file with the standard alerts and the conditions
+// of the configured locations, and the alerts of the exporter itself, which
+// is scraped as job.
+func buildRules(config *Config, job string) *ruleFile {
+	recording := ruleGroup{
+		Name: "openmeteo.rules",
+		Rules: []rule{{
+			Record: apiErrorsRateRecord,
+			Expr:   "sum by (location, api) (rate(openmeteo_api_errors_total[15m]))",
+		}},
+	}
+
+	alerting := ruleGroup{
+		Name: "openmeteo.alerts",
+		Rules: []rule{
+			{
+				Alert:  "OpenMeteoExporterDown",
+				Expr:   fmt.Sprintf("up{job=%q} == 0", job),
+				For:    "5m",
+				Labels: map[string]string{"severity": "critical"},
+				Annotations: map[string]string{
+					"summary":     "Open-Meteo exporter is down",
+					"description": "Prometheus has not been able to scrape {{ $labels.instance }} for 5 minutes.",
+				},
+			},
+			{
+				Alert:  "OpenMeteoAPIErrors",
+				Expr:   apiErrorsRateRecord + " > 0",
+				For:    "30m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary":     "Open-Meteo requests are failing for {{ $labels.location }}",
+					"description": "Requests to the {{ $labels.api }} API have been failing for 30 minutes, see the /status page of the exporter for the reason.",
+				},
+			},
+		},
+	}
+
+	// Group the rules of each alert together, in the order of the
+	// definitions and then of the locations.
+	for i := range alertDefinitions {
+		for j := range config.Locations {
+			for _, a := range config.Locations[j].alerts() {
+				if a.Definition == &alertDefinitions[i] {
+					alerting.Rules = append(alerting.Rules, locationAlertRule(a))
+				}
+			}
+		}
+	}
+
+	for i := range config.Locations {
+		loc := &config.Locations[i]
+		for j := range loc.Conditions {
+			if r, ok := conditionRule(loc, &loc.Conditions[j]); ok {
+				alerting.Rules = append(alerting.Rules, r)
+			} else {
+				level.Warn(logger).Log("msg", "No rule for condition on a variable that is not in the catalogue", "location", loc.Name, "condition", loc.Conditions[j].Name)
+			}
+		}
+	}
+
+	return &ruleFile{Groups: []ruleGroup{recording, alerting}}
+}
+
+// Prints a Prometheus rule file for the locations of the configuration.
+func runRules(job string) error {
+	client, closeClient, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	config, err := loadConfig(client)
+	if err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err = enc.Encode(buildRules(config, job)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import "testing"
+
+func TestBuildRules(t *testing.T) {
+	config := parseConfig(t, `
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    weather:
+      temperature_unit: celsius
+      wind_speed_unit: kmh
+      variables: [temperature_2m, wind_gusts_10m]
+    air_quality:
+      variables: [us_aqi]
+    alerts:
+      extreme_heat:
+        threshold: 33.5
+        for: 1h
+        severity: critical
+      frost:
+        disabled: true
+  - name: Denver
+    latitude: 39.7
+    longitude: -105
+    weather:
+      variables: [temperature_2m]
+`)
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rules := buildRules(config, "weather")
+	if len(rules.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(rules.Groups))
+	}
+	if got := rules.Groups[0].Rules[0].Record; got != apiErrorsRateRecord {
+		t.Errorf("unexpected recording rule %q", got)
+	}
+
+	type alert struct {
+		name, expr, forDuration, severity string
+	}
+	want := []alert{
+		{"OpenMeteoExporterDown", `up{job="weather"} == 0`, "5m", "critical"},
+		{"OpenMeteoAPIErrors", apiErrorsRateRecord + " > 0", "30m", "warning"},
+		{"OpenMeteoExtremeHeat", `openmeteo_weather_temperature_2m_celsius{location="Nice"} >= 33.5`, "1h", "critical"},
+		{"OpenMeteoExtremeHeat", `openmeteo_weather_temperature_2m_fahrenheit{location="Denver"} >= 95`, "30m", "warning"},
+		{"OpenMeteoFrost", `openmeteo_weather_temperature_2m_fahrenheit{location="Denver"} <= 32`, "30m", "info"},
+		{"OpenMeteoHighWindGusts", `openmeteo_weather_wind_gusts_10m_km_per_h{location="Nice"} >= 75`, "15m", "warning"},
+		{"OpenMeteoUnhealthyAirQuality", `openmeteo_airquality_us_aqi_usaqi{location="Nice"} >= 151`, "1h", "warning"},
+	}
+
+	got := rules.Groups[1].Rules
+	if len(got) != len(want) {
+		t.Fatalf("expected %d alerts, got %d: %+v", len(want), len(got), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Alert != w.name || g.Expr != w.expr || g.For != w.forDuration || g.Labels["severity"] != w.severity {
+			t.Errorf("alert %d: got %s %q for %s severity %s, want %+v", i, g.Alert, g.Expr, g.For, g.Labels["severity"], w)
+		}
+	}
+}
+
+func TestBuildRulesConditions(t *testing.T) {
+	config := parseConfig(t, `
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    labels:
+      team: harbour
+    weather:
+      temperature_unit: celsius
+      wind_speed_unit: kmh
+      variables: [temperature_2m, wind_gusts_10m]
+    alerts:
+      high_wind_gusts:
+        disabled: true
+    conditions:
+      - name: HighWind
+        expr: wind_gusts_10m > 50 for 30m
+        severity: critical
+        labels:
+          team: sailing
+      - name: Mild
+        expr: temperature_2m >= 20
+`)
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The conditions follow the standard alerts, with the labels of the
+	// alerts the exporter sends for them.
+	got := buildRules(config, "openmeteo").Groups[1].Rules
+	if len(got) != 6 {
+		t.Fatalf("expected 6 alerts, got %d: %+v", len(got), got)
+	}
+	wind, mild := got[4], got[5]
+	if wind.Alert != "HighWind" || wind.Expr != `openmeteo_weather_wind_gusts_10m_km_per_h{location="Nice"} > 50` || wind.For != "30m" {
+		t.Errorf("unexpected rule %+v", wind)
+	}
+	if wind.Labels["severity"] != "critical" || wind.Labels["team"] != "sailing" || len(wind.Labels) != 2 {
+		t.Errorf("unexpected labels %v", wind.Labels)
+	}
+	if wind.Annotations["description"] != "Wind gusts 10m is {{ $value }}, the condition is > 50 km/h." {
+		t.Errorf("unexpected description %q", wind.Annotations["description"])
+	}
+	if mild.Alert != "Mild" || mild.Expr != `openmeteo_weather_temperature_2m_celsius{location="Nice"} >= 20` || mild.For != "" ||
+		mild.Labels["severity"] != defaultConditionSeverity || mild.Labels["team"] != "harbour" {
+		t.Errorf("unexpected rule %+v", mild)
+	}
+}
+
+func TestDuplicatedAlerts(t *testing.T) {
+	config := parseConfig(t, `
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    weather:
+      temperature_unit: celsius
+      variables: [temperature_2m, wind_gusts_10m]
+    alerts:
+      frost:
+        disabled: true
+    conditions:
+      - name: Heat
+        expr: temperature_2m > 30
+      - name: Cold
+        expr: temperature_2m < 5
+      - name: Gusts
+        expr: wind_gusts_10m >= 60
+      - name: Calm
+        expr: wind_gusts_10m == 0
+`)
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The frost alert is disabled, and only conditions in the direction of
+	// an alert duplicate it.
+	got := config.Locations[0].duplicatedAlerts()
+	want := map[string]string{"Heat": "extreme_heat", "Gusts": "high_wind_gusts"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for name, key := range want {
+		if got[name] != key {
+			t.Errorf("%s: got %q, want %q", name, got[name], key)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+// Lists the variables of an API, or of both APIs when api is empty, in the
+// given output format.
+func runVariables(api, output string) error {
+	return writeVariables(os.Stdout, variableInfos(api), output)
+}
+
+func writeVariables(w io.Writer, variables []VariableInfo, output string) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(variables)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(variables); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "markdown":
+		return writeVariablesMarkdown(w, variables)
+	case "csv":
+		return writeVariablesCSV(w, variables)
+	default:
+		writeVariablesTable(w, variables)
+		return nil
+	}
+}
+
+// Formats the unit of a variable with both unit settings, e.g. "°C / °F".
+func (v VariableInfo) units() string {
+	if v.Metric.Unit == v.Imperial.Unit {
+		return v.Metric.Unit
+	}
+	return v.Metric.Unit + " / " + v.Imperial.Unit
+}
+
+func writeVariablesTable(w io.Writer, variables []VariableInfo) {
+	for _, api := range []string{"weather", "airquality"} {
+		var rows [][]string
+		for _, v := range variables {
+			if v.API == api {
+				rows = append(rows, []string{v.Name, v.Description})
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		if api == "weather" {
+			fmt.Fprintln(w, "Weather Variables")
+		} else {
+			fmt.Fprintln(w, "Air Quality Variables")
+		}
+
+		table := tablewriter.NewWriter(w)
+		table.SetHeader([]string{"Name", "Description"})
+		table.SetRowLine(true)
+		table.SetColWidth(80)
+		table.AppendBulk(rows)
+		table.Render()
+	}
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ")
+
+func writeVariablesMarkdown(w io.Writer, variables []VariableInfo) error {
+	fmt.Fprintln(w, "| API | Name | Category | Type | Unit | Region | Metric | Description |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- | --- | --- | --- | --- |")
+	for _, v := range variables {
+		metric := "`" + v.Metric.MetricName + "`"
+		if v.Imperial.MetricName != v.Metric.MetricName {
+			metric += " / `" + v.Imperial.MetricName + "`"
+		}
+		_, err := fmt.Fprintf(w, "| %s | `%s` | %s | %s | %s | %s | %s | %s |\n",
+			v.API,
+			v.Name,
+			v.Category,
+			v.ValueType,
+			markdownEscaper.Replace(v.units()),
+			v.Region,
+			metric,
+			markdownEscaper.Replace(v.Description),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeVariablesCSV(w io.Writer, variables []VariableInfo) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{ //nolint:errcheck
+		"api", "name", "category", "value_type", "region",
+		"metric_unit", "metric_metric_name", "imperial_unit", "imperial_metric_name",
+		"description",
+	})
+	for _, v := range variables {
+		cw.Write([]string{ //nolint:errcheck
+			v.API, v.Name, v.Category, string(v.ValueType), string(v.Region),
+			v.Metric.Unit, v.Metric.MetricName, v.Imperial.Unit, v.Imperial.MetricName,
+			v.Description,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
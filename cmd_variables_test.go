@@ -0,0 +1,130 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func writeTestVariables(t *testing.T, variables []VariableInfo, output string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeVariables(&buf, variables, output); err != nil {
+		t.Fatalf("%s: unexpected error: %v", output, err)
+	}
+	return buf.String()
+}
+
+func TestWriteVariablesCSV(t *testing.T) {
+	variables := variableInfos("")
+	records, err := csv.NewReader(strings.NewReader(writeTestVariables(t, variables, "csv"))).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid csv: %v", err)
+	}
+	if len(records) != len(variables)+1 {
+		t.Fatalf("expected a header and %d rows, got %d", len(variables), len(records))
+	}
+	if got := strings.Join(records[0], ","); got != "api,name,category,value_type,region,metric_unit,metric_metric_name,imperial_unit,imperial_metric_name,description" {
+		t.Errorf("unexpected header %s", got)
+	}
+
+	want := map[string]string{
+		"weather/temperature_2m":           "weather,temperature_2m,temperature,measurement,global,°C,openmeteo_weather_temperature_2m_celsius,°F,openmeteo_weather_temperature_2m_fahrenheit,Air temperature at 2 meters above ground",
+		"airquality/pm2_5":                 "airquality,pm2_5,particulate matter,measurement,global,μg/m³,openmeteo_airquality_pm2_5_ug_per_m3,μg/m³,openmeteo_airquality_pm2_5_ug_per_m3,Particulate matter with diameter smaller than 2.5 µm (PM2.5) close to surface (10 meter above ground)",
+		"airquality/aerosol_optical_depth": "airquality,aerosol_optical_depth,aerosols,index,global,,openmeteo_airquality_aerosol_optical_depth_,,openmeteo_airquality_aerosol_optical_depth_,Aerosol optical depth at 550 nm of the entire atmosphere to indicate haze.",
+	}
+	for _, record := range records[1:] {
+		key := record[0] + "/" + record[1]
+		if w, ok := want[key]; ok {
+			if got := strings.Join(record, ","); got != w {
+				t.Errorf("%s:\ngot  %s\nwant %s", key, got, w)
+			}
+			delete(want, key)
+		}
+	}
+	for key := range want {
+		t.Errorf("%s is missing", key)
+	}
+}
+
+func TestWriteVariablesMarkdown(t *testing.T) {
+	variables := []VariableInfo{
+		{
+			API: "weather", Name: "temperature_2m", Category: "temperature", ValueType: "measurement", Region: "global",
+			Metric:      UnitMetric{"°C", "openmeteo_weather_temperature_2m_celsius"},
+			Imperial:    UnitMetric{"°F", "openmeteo_weather_temperature_2m_fahrenheit"},
+			Description: "Air temperature\nat 2 meters | above ground",
+		},
+		{
+			API: "airquality", Name: "pm2_5", Category: "particulate matter", ValueType: "measurement", Region: "global",
+			Metric:      UnitMetric{"μg/m³", "openmeteo_airquality_pm2_5_ug_per_m3"},
+			Imperial:    UnitMetric{"μg/m³", "openmeteo_airquality_pm2_5_ug_per_m3"},
+			Description: "PM2.5",
+		},
+	}
+
+	// The pipes and line breaks of the descriptions must not break the table,
+	// and a metric name is only listed twice when the unit settings differ.
+	want := "| API | Name | Category | Type | Unit | Region | Metric | Description |\n" +
+		"| --- | --- | --- | --- | --- | --- | --- | --- |\n" +
+		"| weather | `temperature_2m` | temperature | measurement | °C / °F | global | `openmeteo_weather_temperature_2m_celsius` / `openmeteo_weather_temperature_2m_fahrenheit` | Air temperature at 2 meters \\| above ground |\n" +
+		"| airquality | `pm2_5` | particulate matter | measurement | μg/m³ | global | `openmeteo_airquality_pm2_5_ug_per_m3` | PM2.5 |\n"
+	if got := writeTestVariables(t, variables, "markdown"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteVariablesStructured(t *testing.T) {
+	variables := variableInfos("weather")
+
+	var fromJSON []VariableInfo
+	if err := json.Unmarshal([]byte(writeTestVariables(t, variables, "json")), &fromJSON); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	var fromYAML []VariableInfo
+	if err := yaml.Unmarshal([]byte(writeTestVariables(t, variables, "yaml")), &fromYAML); err != nil {
+		t.Fatalf("invalid yaml: %v", err)
+	}
+
+	for output, decoded := range map[string][]VariableInfo{"json": fromJSON, "yaml": fromYAML} {
+		if len(decoded) != len(variables) {
+			t.Errorf("%s: expected %d variables, got %d", output, len(variables), len(decoded))
+			continue
+		}
+		for i, v := range decoded {
+			if v.API != "weather" || v.Name != variables[i].Name || v.Metric != variables[i].Metric ||
+				v.Imperial != variables[i].Imperial || len(v.Blocks) != len(variables[i].Blocks) {
+				t.Errorf("%s: got %+v, want %+v", output, v, variables[i])
+			}
+		}
+	}
+}
+
+func TestWriteVariablesTable(t *testing.T) {
+	out := writeTestVariables(t, variableInfos("airquality"), "table")
+	if strings.Contains(out, "Weather Variables") || !strings.Contains(out, "Air Quality Variables") {
+		t.Errorf("expected only the air quality table:\n%s", out)
+	}
+	if !strings.Contains(out, "pm2_5") {
+		t.Errorf("pm2_5 is missing:\n%s", out)
+	}
+}
@@ -16,9 +16,16 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sync"
+	"time"
 
+	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 const namespace = "openmeteo"
@@ -27,7 +34,95 @@ var (
 	infoDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "location", "info"),
 		"Information about the location.",
-		[]string{"location", "latitude", "longitude", "timezone"},
+		[]string{
+			"location", "latitude", "longitude", "timezone",
+			"resolved_name", "country", "country_code", "admin1", "admin2", "admin3", "admin4",
+			"population", "elevation",
+		},
+		nil,
+	)
+
+	timezoneInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "location", "timezone_info"),
+		"The timezone the API resolved for the location and its current abbreviation.",
+		[]string{"location", "resolved_timezone", "timezone_abbreviation"},
+		nil,
+	)
+
+	utcOffsetDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "location", "utc_offset_seconds"),
+		"The offset of the location's timezone from UTC, in seconds.",
+		[]string{"location"},
+		nil,
+	)
+
+	elevationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "location", "elevation_meters"),
+		"The elevation of the location used by the weather model, in meters.",
+		[]string{"location"},
+		nil,
+	)
+
+	gridLatitudeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "location", "grid_latitude_degrees"),
+		"The latitude of the grid cell the API resolved the location to.",
+		[]string{"location", "api"},
+		nil,
+	)
+
+	gridLongitudeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "location", "grid_longitude_degrees"),
+		"The longitude of the grid cell the API resolved the location to.",
+		[]string{"location", "api"},
+		nil,
+	)
+
+	gridDistanceDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "location", "grid_distance_meters"),
+		"The distance between the configured coordinates and the grid cell the API resolved the location to.",
+		[]string{"location", "api"},
+		nil,
+	)
+
+	weatherObservationTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "weather", "observation_timestamp_seconds"),
+		"The time of the current weather values, in seconds since the epoch.",
+		[]string{"location"},
+		nil,
+	)
+
+	weatherObservationIntervalDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "weather", "observation_interval_seconds"),
+		"The interval between updates of the current weather values, in seconds.",
+		[]string{"location"},
+		nil,
+	)
+
+	airqualityObservationTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "airquality", "observation_timestamp_seconds"),
+		"The time of the current air quality values, in seconds since the epoch.",
+		[]string{"location"},
+		nil,
+	)
+
+	airqualityObservationIntervalDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "airquality", "observation_interval_seconds"),
+		"The interval between updates of the current air quality values, in seconds.",
+		[]string{"location"},
+		nil,
+	)
+
+	timeoutDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "request_timed_out"),
+		"Whether the request to the API was cancelled because the scrape timed out.",
+		[]string{"location", "api"},
+		nil,
+	)
+
+	responseSourceDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "response_source"),
+		"Whether the exported values of the API were fetched from Open-Meteo (live) or served from the cache (cache), 1 for the current source.",
+		[]string{"location", "api", "source"},
 		nil,
 	)
 
@@ -49,33 +144,331 @@ var (
 type OpenMeteoCollector struct {
 	Client    *OpenMeteoClient
 	Locations []LocationConfig
+
+	// Attach the observation time reported by the API to the variable
+	// metrics instead of letting Prometheus use the scrape time.
+	UseObservationTimestamps bool
+
+	// Tracks the outcome of the requests for the status page, may be nil.
+	Status *StatusTracker
+
+	// Keeps the last successful responses, may be nil.
+	Cache *ResponseCache
 }
 
 func (c OpenMeteoCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- infoDesc
+	ch <- timezoneInfoDesc
+	ch <- utcOffsetDesc
+	ch <- elevationDesc
+	ch <- gridLatitudeDesc
+	ch <- gridLongitudeDesc
+	ch <- gridDistanceDesc
+	ch <- weatherObservationTimeDesc
+	ch <- weatherObservationIntervalDesc
+	ch <- airqualityObservationTimeDesc
+	ch <- airqualityObservationIntervalDesc
+	ch <- timeoutDesc
 	ch <- weatherGenerationTimeDesc
+	ch <- airqualityGenerationTimeDesc
+	ch <- responseSourceDesc
 }
 
 func (c OpenMeteoCollector) Collect(ch chan<- prometheus.Metric) {
-	for _, loc := range c.Locations {
+	c.CollectWithContext(context.Background(), ch)
+}
+
+// CollectWithContext collects all locations concurrently. Requests still in
+// flight when ctx is done are cancelled and the metrics of the requests that
+// completed are still returned.
+func (c OpenMeteoCollector) CollectWithContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	for _, data := range c.Fetch(ctx) {
+		c.collectLocation(ch, data)
+	}
+}
+
+// Fetch queries the current conditions of all locations concurrently.
+// Locations that make identical requests share a single request.
+func (c OpenMeteoCollector) Fetch(ctx context.Context) []*LocationData {
+	ctx = withRequestGroup(ctx)
+	results := make([]*LocationData, len(c.Locations))
+
+	var wg sync.WaitGroup
+	for i := range c.Locations {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.fetchLocation(ctx, &c.Locations[i])
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (c OpenMeteoCollector) fetchLocation(ctx context.Context, loc *LocationConfig) (data *LocationData) {
+	data = &LocationData{Location: loc, FetchedAt: time.Now()}
+
+	// A bad response for one location must never take down the exporter.
+	// What was fetched before the panic is kept, and the APIs that were not
+	// fetched fail.
+	defer func() {
+		if r := recover(); r != nil {
+			level.Error(logger).Log("msg", "Recovered from panic while fetching location", "location", loc.Name, "panic", r)
+			err := fmt.Errorf("panic while fetching location: %v", r)
+			if loc.Weather != nil && data.Weather == nil && data.WeatherErr == nil {
+				data.WeatherErr = err
+			}
+			if loc.AirQuality != nil && data.AirQuality == nil && data.AirQualityErr == nil {
+				data.AirQualityErr = err
+			}
+		}
+	}()
+
+	var cached CachedLocation
+	if c.Cache != nil {
+		cached, _ = c.Cache.Get(loc.Name)
+	}
+
+	if loc.Weather != nil {
+		data.WeatherSource = SourceLive
+		if cached.Weather != nil && c.Cache.Fresh(cached.WeatherFetchedAt, data.FetchedAt) {
+			data.Weather, data.WeatherSource = cached.Weather, SourceCache
+		} else {
+			data.Weather, data.WeatherErr = c.Client.GetWeather(ctx, loc)
+			recordResult(c.Status, loc, "weather", data.WeatherErr)
+			if data.WeatherErr != nil && cached.Weather != nil && c.Cache.Usable(cached.WeatherFetchedAt, data.FetchedAt) {
+				level.Warn(logger).Log("msg", "Serving cached response", "location", loc.Name, "api", "weather", "fetched_at", cached.WeatherFetchedAt)
+				data.Weather, data.WeatherSource = cached.Weather, SourceCache
+			}
+		}
+	}
+
+	if loc.AirQuality != nil {
+		data.AirQualitySource = SourceLive
+		if cached.AirQuality != nil && c.Cache.Fresh(cached.AirQualityFetchedAt, data.FetchedAt) {
+			data.AirQuality, data.AirQualitySource = cached.AirQuality, SourceCache
+		} else {
+			data.AirQuality, data.AirQualityErr = c.Client.GetAirQuality(ctx, loc)
+			recordResult(c.Status, loc, "airquality", data.AirQualityErr)
+			if data.AirQualityErr != nil && cached.AirQuality != nil && c.Cache.Usable(cached.AirQualityFetchedAt, data.FetchedAt) {
+				level.Warn(logger).Log("msg", "Serving cached response", "location", loc.Name, "api", "airquality", "fetched_at", cached.AirQualityFetchedAt)
+				data.AirQuality, data.AirQualitySource = cached.AirQuality, SourceCache
+			}
+		}
+	}
+
+	if c.Cache != nil {
+		c.Cache.Update(data)
+	}
+	return data
+}
+
+func (c OpenMeteoCollector) collectLocation(ch chan<- prometheus.Metric, data *LocationData) {
+	if data == nil {
+		return
+	}
+	loc := data.Location
+
+	defer func() {
+		if r := recover(); r != nil {
+			level.Error(logger).Log("msg", "Recovered from panic while collecting location", "location", loc.Name, "panic", r)
+		}
+	}()
+
+	collectInfo(ch, loc, data.Base())
+
+	if loc.Weather != nil {
+		collectTimeout(ch, loc, "weather", data.WeatherErr)
+	}
+	if loc.AirQuality != nil {
+		collectTimeout(ch, loc, "airquality", data.AirQualityErr)
+	}
+
+	if data.Weather != nil {
+		collectSource(ch, loc, "weather", data.WeatherSource)
+	}
+	if data.AirQuality != nil {
+		collectSource(ch, loc, "airquality", data.AirQualitySource)
+	}
+
+	// Cached responses can be hours old, and Prometheus rejects samples that
+	// old, so they are exported at the scrape time.
+	if data.Weather != nil {
+		weatherCollector := WeatherCollector{
+			Location:                 loc,
+			Response:                 data.Weather,
+			UseObservationTimestamps: c.UseObservationTimestamps && data.WeatherSource != SourceCache,
+		}
+		weatherCollector.Collect(ch)
+	}
+
+	if data.AirQuality != nil {
+		airqualityCollector := AirQualityCollector{
+			Location:                 loc,
+			Response:                 data.AirQuality,
+			UseObservationTimestamps: c.UseObservationTimestamps && data.AirQualitySource != SourceCache,
+		}
+		airqualityCollector.Collect(ch)
+	}
+}
+
+// dataCollector exports already fetched data with the regular collectors, so
+// that pushed metrics have exactly the same names as scraped ones.
+type dataCollector struct {
+	collector OpenMeteoCollector
+	data      []*LocationData
+}
+
+func (c dataCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.collector.Describe(ch)
+}
+
+func (c dataCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, d := range c.data {
+		c.collector.collectLocation(ch, d)
+	}
+}
+
+func gatherLocationData(collector OpenMeteoCollector, data []*LocationData) ([]*dto.MetricFamily, error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(dataCollector{collector: collector, data: data}); err != nil {
+		return nil, err
+	}
+	return registry.Gather()
+}
+
+// Exports the info metric of the location, which only has labels from the
+// configuration so that it keeps its identity for joins. The timezone the API
+// resolved for the location is exported separately, when a response is
+// available, as its abbreviation changes with daylight saving time.
+func collectInfo(ch chan<- prometheus.Metric, loc *LocationConfig, resp *BaseResponse) {
+	// Only populated for locations that were resolved with the Geocoding API.
+	var geo GeocodingResult
+	var population, elevation string
+	if loc.Geocoded != nil {
+		geo = *loc.Geocoded
+		population = fmt.Sprintf("%d", geo.Population)
+		elevation = fmt.Sprintf("%f", geo.Elevation)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		infoDesc,
+		prometheus.GaugeValue,
+		1,
+		loc.Name,
+		fmt.Sprintf("%f", *loc.Latitude),
+		fmt.Sprintf("%f", *loc.Longitude),
+		loc.Timezone,
+		geo.Name,
+		geo.Country,
+		geo.CountryCode,
+		geo.Admin1,
+		geo.Admin2,
+		geo.Admin3,
+		geo.Admin4,
+		population,
+		elevation,
+	)
+
+	if resp != nil {
 		ch <- prometheus.MustNewConstMetric(
-			infoDesc,
+			timezoneInfoDesc,
 			prometheus.GaugeValue,
 			1,
 			loc.Name,
-			fmt.Sprintf("%f", loc.Latitude),
-			fmt.Sprintf("%f", loc.Longitude),
-			loc.Timezone,
+			resp.Timezone,
+			resp.TimezoneAbbreviation,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			utcOffsetDesc,
+			prometheus.GaugeValue,
+			float64(resp.UTCOffsetSeconds),
+			loc.Name,
+		)
+	}
+}
+
+// Exports the coordinates of the grid cell the API snapped the location to.
+func collectGrid(ch chan<- prometheus.Metric, loc *LocationConfig, api string, resp *BaseResponse) {
+	ch <- prometheus.MustNewConstMetric(gridLatitudeDesc, prometheus.GaugeValue, resp.Latitude, loc.Name, api)
+	ch <- prometheus.MustNewConstMetric(gridLongitudeDesc, prometheus.GaugeValue, resp.Longitude, loc.Name, api)
+	ch <- prometheus.MustNewConstMetric(
+		gridDistanceDesc,
+		prometheus.GaugeValue,
+		haversineDistance(*loc.Latitude, *loc.Longitude, resp.Latitude, resp.Longitude),
+		loc.Name,
+		api,
+	)
+}
+
+// Returns the great-circle distance between two points, in meters.
+func haversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000
+
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Exports the time and update interval of the current values.
+func collectObservation(ch chan<- prometheus.Metric, loc *LocationConfig, timeDesc, intervalDesc *prometheus.Desc, resp *BaseResponse) {
+	if resp.Current.Interval > 0 {
+		ch <- prometheus.MustNewConstMetric(intervalDesc, prometheus.GaugeValue, float64(resp.Current.Interval), loc.Name)
+	}
+
+	ts, err := resp.ObservationTime()
+	if err != nil {
+		level.Warn(logger).Log("msg", "Failed to parse observation time", "location", loc.Name, "time", resp.Current.Time, "err", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(timeDesc, prometheus.GaugeValue, float64(ts.Unix()), loc.Name)
+}
+
+// Exports the current value of each of the variables. Variables without a
+// value are skipped rather than failing the whole collection.
+func collectVariables(ch chan<- prometheus.Metric, loc *LocationConfig, api string, names []string, resp *BaseResponse, useTimestamp bool) {
+	for _, sample := range variableSamples(loc, api, names, resp) {
+		description := variableDescription(api, sample.Variable)
+		desc := prometheus.NewDesc(
+			sample.MetricName,
+			description,
+			[]string{"location"},
+			nil,
 		)
 
-		if loc.Weather != nil {
-			weatherCollector := WeatherCollector{Client: c.Client, Location: &loc}
-			weatherCollector.Collect(ch)
+		metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, sample.Value, loc.Name)
+		if err != nil {
+			level.Warn(logger).Log("msg", "Failed to create metric", "location", loc.Name, "name", sample.Variable, "err", err)
+			continue
+		}
+
+		if useTimestamp && !sample.Time.IsZero() {
+			metric = prometheus.NewMetricWithTimestamp(sample.Time, metric)
 		}
+		ch <- metric
+	}
+}
+
+func collectTimeout(ch chan<- prometheus.Metric, loc *LocationConfig, api string, err error) {
+	var timedOut float64
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		timedOut = 1
+	}
+	ch <- prometheus.MustNewConstMetric(timeoutDesc, prometheus.GaugeValue, timedOut, loc.Name, api)
+}
 
-		if loc.AirQuality != nil {
-			airqualityCollector := AirQualityCollector{Client: c.Client, Location: &loc}
-			airqualityCollector.Collect(ch)
+func collectSource(ch chan<- prometheus.Metric, loc *LocationConfig, api string, source ResponseSource) {
+	for _, s := range responseSources {
+		var value float64
+		if s == source {
+			value = 1
 		}
+		ch <- prometheus.MustNewConstMetric(responseSourceDesc, prometheus.GaugeValue, value, loc.Name, api, string(s))
 	}
 }
@@ -0,0 +1,307 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/thelande/openmeteo_exporter/internal/fakeopenmeteo"
+)
+
+// Returns the values of the metrics of a family by location.
+func metricValues(t *testing.T, families []*dto.MetricFamily, name string) map[string]float64 {
+	t.Helper()
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		values := make(map[string]float64)
+		for _, m := range f.GetMetric() {
+			var location string
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "location" {
+					location = l.GetValue()
+				}
+			}
+			values[location] = m.GetGauge().GetValue()
+		}
+		return values
+	}
+	t.Fatalf("metric %s not found", name)
+	return nil
+}
+
+func TestCollectorFake(t *testing.T) {
+	client, _ := newFakeClient(t)
+
+	metric := testLocation("Metric", []string{"temperature_2m", "wind_speed_10m"}, []string{"european_aqi", "pm2_5"})
+	metric.Weather.TemperatureUnit = "celsius"
+	metric.Weather.WindSpeedUnit = "kmh"
+	imperial := testLocation("Imperial", []string{"temperature_2m"}, nil)
+
+	config := Config{Locations: []LocationConfig{metric, imperial}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(OpenMeteoCollector{Client: client, Locations: config.Locations})
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	tests := []struct {
+		metric   string
+		location string
+		want     float64
+	}{
+		{"openmeteo_weather_temperature_2m_celsius", "Metric", 18.4},
+		{"openmeteo_weather_wind_speed_10m_km_per_h", "Metric", 14.8},
+		{"openmeteo_weather_temperature_2m_fahrenheit", "Imperial", 65.12},
+		{"openmeteo_airquality_european_aqi_eaqi", "Metric", 34},
+		{"openmeteo_airquality_pm2_5_ug_per_m3", "Metric", 8.3},
+		{"openmeteo_weather_observation_interval_seconds", "Imperial", 900},
+		{"openmeteo_location_info", "Imperial", 1},
+	}
+	for _, tt := range tests {
+		values := metricValues(t, families, tt.metric)
+		if got, ok := values[tt.location]; !ok || got != tt.want {
+			t.Errorf("%s{location=%q} = %v (found %v), want %v", tt.metric, tt.location, got, ok, tt.want)
+		}
+	}
+}
+
+// Returns a collector of the fake server for the given locations.
+func fakeCollector(t *testing.T, locations ...LocationConfig) (*OpenMeteoCollector, *fakeopenmeteo.Server) {
+	t.Helper()
+	client, fake := newFakeClient(t)
+	config := Config{Locations: locations}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	return &OpenMeteoCollector{Client: client, Locations: config.Locations}, fake
+}
+
+// Compares the metrics of a collection with the given names to want, in the
+// text exposition format. The variable metrics are created on the fly, so a
+// pedantic registry can't be used.
+func compareCollected(t *testing.T, collector *OpenMeteoCollector, want string, names ...string) {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(*collector)
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), names...); err != nil {
+		t.Error(err)
+	}
+}
+
+// Fails the test if any of the metrics with the given names are collected.
+func assertNotCollected(t *testing.T, collector *OpenMeteoCollector, names ...string) {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(*collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, f := range families {
+		if slices.Contains(names, f.GetName()) {
+			t.Errorf("unexpected metric %s: %v", f.GetName(), f.GetMetric())
+		}
+	}
+}
+
+func TestCollectorTimezone(t *testing.T) {
+	collector, fake := fakeCollector(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+
+	// The resolved timezone is exported separately so that the info metric
+	// only has labels from the configuration.
+	want := `
+# HELP openmeteo_location_info Information about the location.
+# TYPE openmeteo_location_info gauge
+openmeteo_location_info{admin1="",admin2="",admin3="",admin4="",country="",country_code="",elevation="",latitude="43.700000",location="Nice",longitude="7.270000",population="",resolved_name="",timezone="auto"} 1
+# HELP openmeteo_location_timezone_info The timezone the API resolved for the location and its current abbreviation.
+# TYPE openmeteo_location_timezone_info gauge
+openmeteo_location_timezone_info{location="Nice",resolved_timezone="Europe/Paris",timezone_abbreviation="CEST"} 1
+# HELP openmeteo_location_utc_offset_seconds The offset of the location's timezone from UTC, in seconds.
+# TYPE openmeteo_location_utc_offset_seconds gauge
+openmeteo_location_utc_offset_seconds{location="Nice"} 7200
+`
+	names := []string{"openmeteo_location_info", "openmeteo_location_timezone_info", "openmeteo_location_utc_offset_seconds"}
+	compareCollected(t, collector, want, names...)
+
+	// Without a response only the info metric is left, unchanged. Both
+	// checks below collect once.
+	fake.InjectError(http.StatusInternalServerError, "Internal error", 2)
+	want = `
+# HELP openmeteo_location_info Information about the location.
+# TYPE openmeteo_location_info gauge
+openmeteo_location_info{admin1="",admin2="",admin3="",admin4="",country="",country_code="",elevation="",latitude="43.700000",location="Nice",longitude="7.270000",population="",resolved_name="",timezone="auto"} 1
+`
+	compareCollected(t, collector, want, "openmeteo_location_info")
+	assertNotCollected(t, collector, names[1:]...)
+}
+
+func TestCollectorElevationAndGrid(t *testing.T) {
+	defaulted := testLocation("Nice", []string{"temperature_2m"}, []string{"pm2_5"})
+	overridden := testLocation("Nice Hills", []string{"temperature_2m"}, nil)
+	overridden.Elevation = float64Ptr(520)
+	collector, fake := fakeCollector(t, defaulted, overridden)
+
+	// The fake snaps the coordinates to a 0.05° grid, 7.27 to 7.25.
+	want := `
+# HELP openmeteo_location_elevation_meters The elevation of the location used by the weather model, in meters.
+# TYPE openmeteo_location_elevation_meters gauge
+openmeteo_location_elevation_meters{location="Nice"} 38
+openmeteo_location_elevation_meters{location="Nice Hills"} 520
+# HELP openmeteo_location_grid_latitude_degrees The latitude of the grid cell the API resolved the location to.
+# TYPE openmeteo_location_grid_latitude_degrees gauge
+openmeteo_location_grid_latitude_degrees{api="airquality",location="Nice"} 43.7
+openmeteo_location_grid_latitude_degrees{api="weather",location="Nice"} 43.7
+openmeteo_location_grid_latitude_degrees{api="weather",location="Nice Hills"} 43.7
+# HELP openmeteo_location_grid_longitude_degrees The longitude of the grid cell the API resolved the location to.
+# TYPE openmeteo_location_grid_longitude_degrees gauge
+openmeteo_location_grid_longitude_degrees{api="airquality",location="Nice"} 7.25
+openmeteo_location_grid_longitude_degrees{api="weather",location="Nice"} 7.25
+openmeteo_location_grid_longitude_degrees{api="weather",location="Nice Hills"} 7.25
+`
+	names := []string{
+		"openmeteo_location_elevation_meters",
+		"openmeteo_location_grid_latitude_degrees",
+		"openmeteo_location_grid_longitude_degrees",
+	}
+	compareCollected(t, collector, want, names...)
+
+	// 0.02° of longitude at 43.7° of latitude.
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(*collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for location, got := range metricValues(t, families, "openmeteo_location_grid_distance_meters") {
+		if math.Abs(got-1607.8) > 0.1 {
+			t.Errorf("openmeteo_location_grid_distance_meters{location=%q} = %v, want 1607.8", location, got)
+		}
+	}
+	names = append(names, "openmeteo_location_grid_distance_meters")
+
+	// The metrics come from the responses, so there are none when every
+	// request fails.
+	fake.InjectError(http.StatusInternalServerError, "Internal error", 3)
+	assertNotCollected(t, collector, names...)
+}
+
+func TestCollectorObservationTime(t *testing.T) {
+	collector, fake := fakeCollector(t, testLocation("Nice", []string{"temperature_2m"}, []string{"pm2_5"}))
+
+	// The fixtures are for 2024-06-01T12:00Z, returned in the local time of
+	// Nice.
+	want := `
+# HELP openmeteo_airquality_observation_interval_seconds The interval between updates of the current air quality values, in seconds.
+# TYPE openmeteo_airquality_observation_interval_seconds gauge
+openmeteo_airquality_observation_interval_seconds{location="Nice"} 3600
+# HELP openmeteo_airquality_observation_timestamp_seconds The time of the current air quality values, in seconds since the epoch.
+# TYPE openmeteo_airquality_observation_timestamp_seconds gauge
+openmeteo_airquality_observation_timestamp_seconds{location="Nice"} 1.7172432e+09
+# HELP openmeteo_weather_observation_interval_seconds The interval between updates of the current weather values, in seconds.
+# TYPE openmeteo_weather_observation_interval_seconds gauge
+openmeteo_weather_observation_interval_seconds{location="Nice"} 900
+# HELP openmeteo_weather_observation_timestamp_seconds The time of the current weather values, in seconds since the epoch.
+# TYPE openmeteo_weather_observation_timestamp_seconds gauge
+openmeteo_weather_observation_timestamp_seconds{location="Nice"} 1.7172432e+09
+`
+	names := []string{
+		"openmeteo_airquality_observation_interval_seconds",
+		"openmeteo_airquality_observation_timestamp_seconds",
+		"openmeteo_weather_observation_interval_seconds",
+		"openmeteo_weather_observation_timestamp_seconds",
+	}
+	compareCollected(t, collector, want, names...)
+
+	// The variables use the scrape time by default.
+	variables := `
+# HELP openmeteo_weather_temperature_2m_fahrenheit Air temperature at 2 meters above ground
+# TYPE openmeteo_weather_temperature_2m_fahrenheit gauge
+openmeteo_weather_temperature_2m_fahrenheit{location="Nice"} 65.12
+# HELP openmeteo_airquality_pm2_5_ug_per_m3 Particulate matter with diameter smaller than 2.5 µm (PM2.5) close to surface (10 meter above ground)
+# TYPE openmeteo_airquality_pm2_5_ug_per_m3 gauge
+openmeteo_airquality_pm2_5_ug_per_m3{location="Nice"} 8.3
+`
+	variableNames := []string{"openmeteo_weather_temperature_2m_fahrenheit", "openmeteo_airquality_pm2_5_ug_per_m3"}
+	compareCollected(t, collector, variables, variableNames...)
+
+	// Or the observation time, when enabled.
+	collector.UseObservationTimestamps = true
+	variables = `
+# HELP openmeteo_weather_temperature_2m_fahrenheit Air temperature at 2 meters above ground
+# TYPE openmeteo_weather_temperature_2m_fahrenheit gauge
+openmeteo_weather_temperature_2m_fahrenheit{location="Nice"} 65.12 1717243200000
+# HELP openmeteo_airquality_pm2_5_ug_per_m3 Particulate matter with diameter smaller than 2.5 µm (PM2.5) close to surface (10 meter above ground)
+# TYPE openmeteo_airquality_pm2_5_ug_per_m3 gauge
+openmeteo_airquality_pm2_5_ug_per_m3{location="Nice"} 8.3 1717243200000
+`
+	compareCollected(t, collector, variables, variableNames...)
+
+	fake.InjectError(http.StatusInternalServerError, "Internal error", 2)
+	assertNotCollected(t, collector, append(names, variableNames...)...)
+}
+
+func TestCollectorRecoversFromPanic(t *testing.T) {
+	client, _ := newFakeClient(t)
+	client.HTTPClient = &http.Client{Transport: panickingTransport{}}
+	config := Config{Locations: []LocationConfig{
+		testLocation("Nice", []string{"temperature_2m"}, []string{"pm2_5"}),
+		testLocation("Denver", []string{"temperature_2m"}, nil),
+	}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	collector := &OpenMeteoCollector{Client: client, Locations: config.Locations}
+
+	// The weather fetched before the panic is kept.
+	data := collector.Fetch(context.Background())
+	if data[0] == nil || data[0].Weather == nil || data[0].AirQuality != nil || data[0].AirQualityErr == nil {
+		t.Fatalf("expected the weather and an air quality error, got %+v", data[0])
+	}
+	if samples := data[0].Samples(); len(samples) != 1 || samples[0].Variable != "temperature_2m" {
+		t.Errorf("unexpected samples %+v", samples)
+	}
+
+	compareCollected(t, collector, `
+# HELP openmeteo_weather_temperature_2m_fahrenheit Air temperature at 2 meters above ground
+# TYPE openmeteo_weather_temperature_2m_fahrenheit gauge
+openmeteo_weather_temperature_2m_fahrenheit{location="Denver"} 65.12
+openmeteo_weather_temperature_2m_fahrenheit{location="Nice"} 65.12
+`, "openmeteo_weather_temperature_2m_fahrenheit")
+	assertNotCollected(t, collector, "openmeteo_airquality_pm2_5_ug_per_m3")
+
+	// Missing data is skipped rather than dereferenced.
+	if samples := (*LocationData)(nil).Samples(); samples != nil {
+		t.Errorf("unexpected samples %+v", samples)
+	}
+	if _, err := gatherLocationData(*collector, []*LocationData{nil, data[1]}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
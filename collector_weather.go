@@ -16,27 +16,18 @@ limitations under the License.
 package main
 
 import (
-	"fmt"
-
-	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type WeatherCollector struct {
-	Client   *OpenMeteoClient
 	Location *LocationConfig
+	Response *WeatherResponse
+
+	UseObservationTimestamps bool
 }
 
 func (c WeatherCollector) Collect(ch chan<- prometheus.Metric) {
-	weatherResp, err := c.Client.GetWeather(c.Location)
-	if err != nil {
-		level.Warn(logger).Log(
-			"msg", "Failed to collect weather information",
-			"location", c.Location.Name,
-			"err", err,
-		)
-		return
-	}
+	weatherResp := c.Response
 
 	ch <- prometheus.MustNewConstMetric(
 		weatherGenerationTimeDesc,
@@ -45,46 +36,15 @@ func (c WeatherCollector) Collect(ch chan<- prometheus.Metric) {
 		c.Location.Name,
 	)
 
-	for _, name := range c.Location.Weather.Variables {
-		units := weatherResp.CurrentUnits.Variables[name]
-
-		// Convert the returned units to something Prometheus will accept.
-		if units == "°F" {
-			units = "fahrenheit"
-		} else if units == "°C" {
-			units = "celsius"
-		} else if units == "%" {
-			units = "percent"
-		} else if units == "wmo code" {
-			units = ""
-		}
-
-		description, _ := GetVariableDesc("weather", name)
-
-		// Omit the underscore separating the name and units if there are no units.
-		var fqname string
-		if units != "" {
-			fqname = prometheus.BuildFQName(namespace, "weather", fmt.Sprintf("%s_%s", name, units))
-		} else {
-			fqname = prometheus.BuildFQName(namespace, "weather", name)
-		}
+	ch <- prometheus.MustNewConstMetric(
+		elevationDesc,
+		prometheus.GaugeValue,
+		weatherResp.Elevation,
+		c.Location.Name,
+	)
+	collectGrid(ch, c.Location, "weather", &weatherResp.BaseResponse)
 
-		desc := prometheus.NewDesc(
-			fqname,
-			description,
-			[]string{"location"},
-			nil,
-		)
+	collectObservation(ch, c.Location, weatherObservationTimeDesc, weatherObservationIntervalDesc, &weatherResp.BaseResponse)
 
-		if value := weatherResp.Current.Variables[name]; value != nil {
-			ch <- prometheus.MustNewConstMetric(
-				desc,
-				prometheus.GaugeValue,
-				float64(value.(float64)),
-				c.Location.Name,
-			)
-		} else {
-			level.Warn(logger).Log("msg", "No value for metric returned", "name", name)
-		}
-	}
+	collectVariables(ch, c.Location, "weather", c.Location.Weather.Variables, &weatherResp.BaseResponse, c.UseObservationTimestamps)
 }
@@ -16,6 +16,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -32,30 +33,56 @@ const (
 )
 
 type AirQualityConfig struct {
-	Variables []string `yaml:"variables"`
+	Variables []string `yaml:"variables,omitempty"`
 }
 
 type WeatherConfig struct {
-	TemperatureUnit   string   `yaml:"temperature_unit"`
-	WindSpeedUnit     string   `yaml:"wind_speed_unit"`
-	PrecipitationUnit string   `yaml:"precipitation_unit"`
-	Variables         []string `yaml:"variables"`
+	TemperatureUnit   string   `yaml:"temperature_unit,omitempty"`
+	WindSpeedUnit     string   `yaml:"wind_speed_unit,omitempty"`
+	PrecipitationUnit string   `yaml:"precipitation_unit,omitempty"`
+	Variables         []string `yaml:"variables,omitempty"`
 }
 
 type LocationConfig struct {
-	Name       string            `yaml:"name"`
-	Latitude   float64           `yaml:"latitude"`
-	Longitude  float64           `yaml:"longitude"`
-	Timezone   string            `yaml:"timezone"`
-	Weather    *WeatherConfig    `yaml:"weather"`
-	AirQuality *AirQualityConfig `yaml:"air_quality"`
+	Name       string            `yaml:"name,omitempty"`
+	Latitude   *float64          `yaml:"latitude,omitempty"`
+	Longitude  *float64          `yaml:"longitude,omitempty"`
+	Timezone   string            `yaml:"timezone,omitempty"`
+	Elevation  *float64          `yaml:"elevation,omitempty"`
+	Weather    *WeatherConfig    `yaml:"weather,omitempty"`
+	AirQuality *AirQualityConfig `yaml:"air_quality,omitempty"`
+	Alerts     AlertsConfig      `yaml:"alerts,omitempty"`
+	Conditions []ConditionConfig `yaml:"conditions,omitempty"`
+	// Added to the alerts of the conditions of the location.
+	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// Place name or postal code to resolve with the Geocoding API instead of
+	// providing the latitude and longitude.
+	Query       string `yaml:"query,omitempty"`
+	CountryCode string `yaml:"country_code,omitempty"`
+
+	// Set once the query has been resolved.
+	Geocoded *GeocodingResult `yaml:"-"`
 }
 
 type Config struct {
-	Locations []LocationConfig `yaml:"locations"`
+	Locations []LocationConfig `yaml:"locations,omitempty"`
+
+	// Optional outputs the polled data is pushed to.
+	InfluxDB    *InfluxDBConfig    `yaml:"influxdb,omitempty"`
+	RemoteWrite *RemoteWriteConfig `yaml:"remote_write,omitempty"`
+	MQTT        *MQTTConfig        `yaml:"mqtt,omitempty"`
+	OTLP        *OTLPConfig        `yaml:"otlp,omitempty"`
+
+	// Notifications about the conditions of the locations.
+	Alerting *AlertingConfig `yaml:"alerting,omitempty"`
+
+	// Accept variables that are not in the catalogue, e.g. ones added to
+	// the APIs after this release, with a warning instead of an error.
+	LenientVariables bool `yaml:"lenient_variables,omitempty"`
 }
 
-func (c *Config) ReloadConfig(configFile string) error {
+func (c *Config) ReloadConfig(configFile string, geocoder *Geocoder) error {
 	var config []byte
 	var err error
 
@@ -77,35 +104,110 @@ func (c *Config) ReloadConfig(configFile string) error {
 		return err
 	}
 
+	for i := range c.Locations {
+		loc := &c.Locations[i]
+		if loc.Query == "" {
+			continue
+		}
+		if geocoder == nil {
+			return fmt.Errorf("unable to resolve query for location %s, geocoding is disabled", loc.Name)
+		}
+		if err = geocoder.Resolve(context.Background(), loc); err != nil {
+			return err
+		}
+	}
+
 	level.Info(logger).Log("msg", "Loaded configuration file", "path", configFile, "locations", len(c.Locations))
 	return nil
 }
 
 func (c *Config) Validate() error {
-	if c.Locations == nil || len(c.Locations) == 0 {
+	if len(c.Locations) == 0 {
 		return errors.New("invalid config, no locations provided")
 	}
 
-	for _, loc := range c.Locations {
-		if err := loc.Validate(); err != nil {
-			return err
+	// Collect every problem so they can all be fixed in one go.
+	var errs []error
+	names := make(map[string]int)
+	for i := range c.Locations {
+		loc := &c.Locations[i]
+		if err := loc.Validate(c.LenientVariables); err != nil {
+			errs = append(errs, err)
+		}
+
+		// The name is used as the location label, so it must be unique.
+		if len(loc.Name) != 0 {
+			if first, ok := names[loc.Name]; ok {
+				errs = append(errs, fmt.Errorf("invalid location, duplicate name %s at positions %d and %d", loc.Name, first+1, i+1))
+			} else {
+				names[loc.Name] = i
+			}
 		}
 	}
 
-	return nil
+	if c.InfluxDB != nil {
+		if err := c.InfluxDB.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.RemoteWrite != nil {
+		if err := c.RemoteWrite.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.MQTT != nil {
+		if err := c.MQTT.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.OTLP != nil {
+		if err := c.OTLP.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.Alerting != nil {
+		if err := c.Alerting.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-func (l *LocationConfig) Validate() error {
-	if len(l.Name) == 0 {
-		return errors.New("invalid location, no name provided")
+func (l *LocationConfig) Validate(lenient bool) error {
+	var errs []error
+
+	name := l.Name
+	if len(name) == 0 {
+		errs = append(errs, errors.New("invalid location, no name provided"))
+		name = "<unnamed>"
 	}
 
-	if l.Latitude == 0 {
-		return fmt.Errorf("invalid location, no latitude provided: %s", l.Name)
+	if len(l.Query) != 0 {
+		if l.Latitude != nil || l.Longitude != nil {
+			errs = append(errs, fmt.Errorf("invalid location, query cannot be combined with latitude and longitude: %s", name))
+		}
+	} else {
+		if l.Latitude == nil {
+			errs = append(errs, fmt.Errorf("invalid location, no latitude provided: %s", name))
+		} else if *l.Latitude < -90 || *l.Latitude > 90 {
+			errs = append(errs, fmt.Errorf("invalid location, latitude %f is outside of -90 to 90: %s", *l.Latitude, name))
+		}
+
+		if l.Longitude == nil {
+			errs = append(errs, fmt.Errorf("invalid location, no longitude provided: %s", name))
+		} else if *l.Longitude < -180 || *l.Longitude > 180 {
+			errs = append(errs, fmt.Errorf("invalid location, longitude %f is outside of -180 to 180: %s", *l.Longitude, name))
+		}
+
+		if len(l.CountryCode) != 0 {
+			errs = append(errs, fmt.Errorf("invalid location, country_code requires a query: %s", name))
+		}
 	}
 
-	if l.Longitude == 0 {
-		return fmt.Errorf("invalid location, no longitude provided: %s", l.Name)
+	// Mount Everest and the Dead Sea shore bound any sensible elevation.
+	if l.Elevation != nil && (*l.Elevation < -500 || *l.Elevation > 9000) {
+		errs = append(errs, fmt.Errorf("invalid location, elevation %f is outside of -500 to 9000 meters: %s", *l.Elevation, name))
 	}
 
 	// Use auto if no timezone is set to enable automatic detection based on
@@ -115,31 +217,39 @@ func (l *LocationConfig) Validate() error {
 	}
 
 	if l.Weather != nil {
-		if err := l.Weather.Validate(l); err != nil {
-			return err
-		}
+		errs = append(errs, l.Weather.Validate(name)...)
+		errs = append(errs, validateVariables(name, "weather", l.Weather.Variables, lenient)...)
 	}
 	if l.AirQuality != nil {
-		if err := l.AirQuality.Validate(l); err != nil {
-			return err
+		errs = append(errs, l.AirQuality.Validate(name)...)
+		errs = append(errs, validateVariables(name, "airquality", l.AirQuality.Variables, lenient)...)
+	}
+	if l.Alerts != nil {
+		errs = append(errs, l.Alerts.Validate(l, name)...)
+	}
+	errs = append(errs, validateAlertLabels(l.Labels, "location: "+name)...)
+	conditions := make(map[string]bool)
+	for i := range l.Conditions {
+		c := &l.Conditions[i]
+		errs = append(errs, c.Validate(l, name)...)
+		if len(c.Name) != 0 && conditions[c.Name] {
+			errs = append(errs, fmt.Errorf("invalid condition, duplicate name %s, for location: %s", c.Name, name))
 		}
+		conditions[c.Name] = true
 	}
+	l.warnRegionalVariables()
+	l.warnDuplicatedAlerts()
 	if l.Weather == nil && l.AirQuality == nil {
-		return fmt.Errorf("invalid location, no weather or air_quality sections defined: %s", l.Name)
+		errs = append(errs, fmt.Errorf("invalid location, no weather or air_quality sections defined: %s", name))
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
-func (w *WeatherConfig) Validate(l *LocationConfig) error {
+func (w *WeatherConfig) Validate(location string) []error {
+	var errs []error
 	if len(w.Variables) == 0 {
-		return fmt.Errorf("invalid weather config, no entries for variables: %s", l.Name)
-	}
-
-	for _, name := range w.Variables {
-		if !IsValidVariable("weather", name) {
-			return fmt.Errorf("invalid current weather variable, %s, for location: %s", name, l.Name)
-		}
+		errs = append(errs, fmt.Errorf("invalid weather config, no entries for variables: %s", location))
 	}
 
 	if len(w.TemperatureUnit) == 0 {
@@ -147,7 +257,7 @@ func (w *WeatherConfig) Validate(l *LocationConfig) error {
 	}
 
 	if !slices.Contains(ValidTemperatureUnits, w.TemperatureUnit) {
-		return fmt.Errorf("invalid temperature_unit, %s, for location: %s", w.TemperatureUnit, l.Name)
+		errs = append(errs, fmt.Errorf("invalid temperature_unit, %s, for location: %s", w.TemperatureUnit, location))
 	}
 
 	if len(w.WindSpeedUnit) == 0 {
@@ -155,7 +265,7 @@ func (w *WeatherConfig) Validate(l *LocationConfig) error {
 	}
 
 	if !slices.Contains(ValidWindSpeedUnits, w.WindSpeedUnit) {
-		return fmt.Errorf("invalid wind_speed_unit, %s, for location: %s", w.WindSpeedUnit, l.Name)
+		errs = append(errs, fmt.Errorf("invalid wind_speed_unit, %s, for location: %s", w.WindSpeedUnit, location))
 	}
 
 	if len(w.PrecipitationUnit) == 0 {
@@ -163,22 +273,86 @@ func (w *WeatherConfig) Validate(l *LocationConfig) error {
 	}
 
 	if !slices.Contains(ValidPrecipitationUnits, w.PrecipitationUnit) {
-		return fmt.Errorf("invalid precipitation_unit, %s, for location: %s", w.PrecipitationUnit, l.Name)
+		errs = append(errs, fmt.Errorf("invalid precipitation_unit, %s, for location: %s", w.PrecipitationUnit, location))
 	}
 
-	return nil
+	return errs
 }
 
-func (a *AirQualityConfig) Validate(l *LocationConfig) error {
+func (a *AirQualityConfig) Validate(location string) []error {
+	var errs []error
 	if len(a.Variables) == 0 {
-		return fmt.Errorf("invalid air quality config, no entries for variables: %s", l.Name)
+		errs = append(errs, fmt.Errorf("invalid air quality config, no entries for variables: %s", location))
 	}
 
-	for _, name := range a.Variables {
-		if !IsValidVariable("airquality", name) {
-			return fmt.Errorf("invalid current air quality variable, %s, for location: %s", name, l.Name)
+	return errs
+}
+
+// HasConditions reports whether any location has conditions for the
+// exporter to evaluate.
+func (c *Config) HasConditions() bool {
+	for _, loc := range c.Locations {
+		if len(loc.Conditions) > 0 {
+			return true
 		}
 	}
+	return false
+}
+
+// Checks that the variables of an API are in the catalogue and can be
+// requested as current values. Unknown variables are passed through to the
+// API in lenient mode, which rejects them if they don't exist.
+func validateVariables(location, api string, names []string, lenient bool) []error {
+	label := "weather"
+	if api == "airquality" {
+		label = "air quality"
+	}
 
-	return nil
+	var errs []error
+	for _, name := range names {
+		v, err := LookupVariable(api, name)
+		if err != nil && lenient {
+			level.Warn(logger).Log("msg", "Variable is not in the catalogue, passing it through as lenient_variables is enabled", "location", location, "api", api, "variable", name)
+			continue
+		}
+		if err != nil || !v.Supports(BlockCurrent) {
+			errs = append(errs, fmt.Errorf("invalid current %s variable, %s, for location: %s", label, name, location))
+		}
+	}
+	return errs
+}
+
+// Rough bounds of the domain of the European models.
+const (
+	europeMinLatitude  = 30
+	europeMaxLatitude  = 72
+	europeMinLongitude = -25
+	europeMaxLongitude = 45
+)
+
+// Warns about variables that are only available in Europe for locations
+// outside of it, as the API returns no values for them there. Locations
+// given by a query are not resolved yet and are not checked.
+func (l *LocationConfig) warnRegionalVariables() {
+	if l.Latitude == nil || l.Longitude == nil {
+		return
+	}
+	if *l.Latitude >= europeMinLatitude && *l.Latitude <= europeMaxLatitude &&
+		*l.Longitude >= europeMinLongitude && *l.Longitude <= europeMaxLongitude {
+		return
+	}
+
+	warn := func(api string, names []string) {
+		for _, name := range names {
+			if v, err := LookupVariable(api, name); err == nil && v.Region == RegionEurope {
+				level.Warn(logger).Log("msg", "Variable is only available in Europe", "location", l.Name, "api", api, "variable", name)
+			}
+		}
+	}
+	if l.Weather != nil {
+		warn("weather", l.Weather.Variables)
+	}
+	if l.AirQuality != nil {
+		warn("airquality", l.AirQuality.Variables)
+	}
 }
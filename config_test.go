@@ -0,0 +1,241 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func parseConfig(t *testing.T, data string) *Config {
+	t.Helper()
+	var config Config
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	return &config
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		// Substrings of the error, valid if empty.
+		errs []string
+	}{
+		{
+			name: "valid",
+			config: `
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    weather:
+      variables: [temperature_2m, weather_code]
+    air_quality:
+      variables: [european_aqi]
+`,
+		},
+		{
+			name: "zero coordinates",
+			config: `
+locations:
+  - name: Null Island
+    latitude: 0
+    longitude: 0
+    weather:
+      variables: [temperature_2m]
+`,
+		},
+		{
+			name:   "no locations",
+			config: `locations: []`,
+			errs:   []string{"no locations provided"},
+		},
+		{
+			name: "all errors reported",
+			config: `
+locations:
+  - latitude: 91
+    longitude: -181
+    weather:
+      variables: [temperature_2m]
+  - name: Nice
+    weather:
+      variables: [not_a_variable]
+      temperature_unit: kelvin
+`,
+			errs: []string{
+				"no name provided",
+				"latitude 91.000000 is outside of -90 to 90",
+				"longitude -181.000000 is outside of -180 to 180",
+				"no latitude provided: Nice",
+				"no longitude provided: Nice",
+				"not_a_variable",
+				"kelvin",
+			},
+		},
+		{
+			name: "duplicate names",
+			config: `
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    weather:
+      variables: [temperature_2m]
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    weather:
+      variables: [temperature_2m]
+`,
+			errs: []string{"duplicate name Nice at positions 1 and 2"},
+		},
+		{
+			name: "query with coordinates",
+			config: `
+locations:
+  - name: Nice
+    query: Nice
+    latitude: 43.7
+    longitude: 7.27
+    weather:
+      variables: [temperature_2m]
+`,
+			errs: []string{"query cannot be combined with latitude and longitude"},
+		},
+		{
+			name: "no sections",
+			config: `
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+`,
+			errs: []string{"no weather or air_quality sections defined: Nice"},
+		},
+		{
+			name: "invalid alerts",
+			config: `
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    weather:
+      variables: [temperature_2m]
+    alerts:
+      extreme_heat:
+        severity: page
+        for: -1m
+      high_wind_gusts:
+        threshold: 50
+      heat_wave: {}
+      frost:
+        disabled: true
+`,
+			errs: []string{
+				"invalid severity, page, for alert extreme_heat of location: Nice",
+				"invalid alert extreme_heat, for must not be negative",
+				"invalid alert high_wind_gusts, requires the weather variable wind_gusts_10m",
+				"invalid alert, heat_wave, must be one of",
+			},
+		},
+		{
+			name: "invalid alert labels",
+			config: `
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    labels:
+      severity: high
+      team-name: sailing
+    weather:
+      variables: [temperature_2m]
+`,
+			errs: []string{
+				"invalid label, severity is set by the exporter, for location: Nice",
+				"invalid label name, team-name, for location: Nice",
+			},
+		},
+		{
+			name: "lenient variables",
+			config: `
+lenient_variables: true
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    weather:
+      variables: [temperature_2m, some_new_variable]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseConfig(t, tt.config).Validate()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error does not contain %q: %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValidateDefaults(t *testing.T) {
+	config := parseConfig(t, `
+locations:
+  - name: Nice
+    latitude: 43.7
+    longitude: 7.27
+    weather:
+      variables: [temperature_2m]
+`)
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loc := config.Locations[0]
+	if loc.Timezone != "auto" {
+		t.Errorf("expected timezone auto, got %q", loc.Timezone)
+	}
+	if w := loc.Weather; w.TemperatureUnit != defaultTemperatureUnit || w.WindSpeedUnit != defaultWindSpeedUnit || w.PrecipitationUnit != defaultPrecipitationUnit {
+		t.Errorf("unexpected default units: %+v", w)
+	}
+
+	// Validating again must not change anything.
+	before := *config.Locations[0].Weather
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unexpected error validating again: %v", err)
+	}
+	if after := *config.Locations[0].Weather; after.TemperatureUnit != before.TemperatureUnit {
+		t.Errorf("validating again changed the config: %+v", after)
+	}
+}
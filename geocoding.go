@@ -0,0 +1,219 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+var ErrNoGeocodingResults = errors.New("no geocoding results found")
+
+// The longest a lookup may take by default, so that a stalled Geocoding API
+// doesn't hang loading the configuration.
+const defaultGeocodingTimeout = 30 * time.Second
+
+// A single entry in the results list of the Geocoding API.
+// https://open-meteo.com/en/docs/geocoding-api
+type GeocodingResult struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Latitude    float64  `json:"latitude"`
+	Longitude   float64  `json:"longitude"`
+	Elevation   float64  `json:"elevation"`
+	FeatureCode string   `json:"feature_code"`
+	CountryCode string   `json:"country_code"`
+	Country     string   `json:"country"`
+	Timezone    string   `json:"timezone"`
+	Population  int      `json:"population"`
+	Postcodes   []string `json:"postcodes"`
+	Admin1      string   `json:"admin1"`
+	Admin2      string   `json:"admin2"`
+	Admin3      string   `json:"admin3"`
+	Admin4      string   `json:"admin4"`
+}
+
+type GeocodingResponse struct {
+	Results          []GeocodingResult `json:"results"`
+	GenerationtimeMs float32           `json:"generationtime_ms"`
+}
+
+// Returns true if the given qualifier (e.g. "France" or "FR") matches the
+// country or one of the administrative areas of the result.
+func (r *GeocodingResult) matches(qualifier string) bool {
+	for _, field := range []string{r.Country, r.CountryCode, r.Admin1, r.Admin2, r.Admin3, r.Admin4} {
+		if strings.EqualFold(field, qualifier) {
+			return true
+		}
+	}
+	return slices.ContainsFunc(r.Postcodes, func(p string) bool { return strings.EqualFold(p, qualifier) })
+}
+
+// Splits a query such as "Nice, France" into the name that is searched for
+// and the qualifiers used to pick between the returned results.
+func splitGeocodingQuery(query string) (string, []string) {
+	parts := strings.Split(query, ",")
+	name := strings.TrimSpace(parts[0])
+
+	var qualifiers []string
+	for _, part := range parts[1:] {
+		if part = strings.TrimSpace(part); part != "" {
+			qualifiers = append(qualifiers, part)
+		}
+	}
+	return name, qualifiers
+}
+
+type geocodingCacheEntry struct {
+	GeocodingResult
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// Geocoder resolves the query of a location into coordinates. Results are
+// cached in CacheFile, when set, so that the API is only queried the first
+// time a location is seen.
+type Geocoder struct {
+	Client    *OpenMeteoClient
+	CacheFile string
+	// The longest a lookup may take, defaultGeocodingTimeout when zero.
+	Timeout time.Duration
+
+	cache map[string]geocodingCacheEntry
+}
+
+func geocodingCacheKey(query, countryCode string) string {
+	return strings.ToLower(strings.TrimSpace(query)) + "|" + strings.ToUpper(countryCode)
+}
+
+func (g *Geocoder) loadCache() error {
+	g.cache = make(map[string]geocodingCacheEntry)
+	if g.CacheFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(g.CacheFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &g.cache)
+}
+
+func (g *Geocoder) saveCache() error {
+	if g.CacheFile == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(g.cache, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(g.CacheFile), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated cache.
+	tmp := g.CacheFile + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, g.CacheFile)
+}
+
+// Resolve looks up the query of the location and sets its coordinates.
+func (g *Geocoder) Resolve(ctx context.Context, loc *LocationConfig) error {
+	if g.cache == nil {
+		if err := g.loadCache(); err != nil {
+			level.Warn(logger).Log("msg", "Failed to read geocoding cache, ignoring", "path", g.CacheFile, "err", err)
+		}
+	}
+
+	key := geocodingCacheKey(loc.Query, loc.CountryCode)
+	entry, ok := g.cache[key]
+	if !ok {
+		result, err := g.search(ctx, loc.Query, loc.CountryCode)
+		if err != nil {
+			return fmt.Errorf("failed to resolve query %q for location %s: %w", loc.Query, loc.Name, err)
+		}
+
+		entry = geocodingCacheEntry{GeocodingResult: *result, ResolvedAt: time.Now().UTC()}
+		g.cache[key] = entry
+		if err = g.saveCache(); err != nil {
+			level.Warn(logger).Log("msg", "Failed to write geocoding cache", "path", g.CacheFile, "err", err)
+		}
+	}
+
+	level.Info(logger).Log(
+		"msg", "Resolved location",
+		"location", loc.Name,
+		"query", loc.Query,
+		"name", entry.Name,
+		"country", entry.Country,
+		"latitude", entry.Latitude,
+		"longitude", entry.Longitude,
+		"cached", ok,
+	)
+
+	result := entry.GeocodingResult
+	loc.Latitude = &result.Latitude
+	loc.Longitude = &result.Longitude
+	loc.Geocoded = &result
+	return nil
+}
+
+func (g *Geocoder) search(ctx context.Context, query, countryCode string) (*GeocodingResult, error) {
+	name, qualifiers := splitGeocodingQuery(query)
+
+	timeout := g.Timeout
+	if timeout == 0 {
+		timeout = defaultGeocodingTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, err := g.Client.SearchLocation(ctx, name, countryCode)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range resp.Results {
+		result := &resp.Results[i]
+		matched := true
+		for _, q := range qualifiers {
+			if !result.matches(q) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return result, nil
+		}
+	}
+
+	return nil, ErrNoGeocodingResults
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSplitGeocodingQuery(t *testing.T) {
+	tests := []struct {
+		query      string
+		name       string
+		qualifiers []string
+	}{
+		{"Nice", "Nice", nil},
+		{" Nice , France ", "Nice", []string{"France"}},
+		{"Springfield, Illinois, US", "Springfield", []string{"Illinois", "US"}},
+		{"10115,, DE", "10115", []string{"DE"}},
+	}
+	for _, tt := range tests {
+		name, qualifiers := splitGeocodingQuery(tt.query)
+		if name != tt.name || !slices.Equal(qualifiers, tt.qualifiers) {
+			t.Errorf("splitGeocodingQuery(%q) = %q, %q, want %q, %q", tt.query, name, qualifiers, tt.name, tt.qualifiers)
+		}
+	}
+}
+
+func TestGeocodingResultMatches(t *testing.T) {
+	result := GeocodingResult{
+		Name:        "Nice",
+		Country:     "France",
+		CountryCode: "FR",
+		Admin1:      "Provence-Alpes-Côte d'Azur",
+		Postcodes:   []string{"06000", "06100"},
+	}
+	for _, qualifier := range []string{"France", "fr", "provence-alpes-côte d'azur", "06100"} {
+		if !result.matches(qualifier) {
+			t.Errorf("expected %q to match", qualifier)
+		}
+	}
+	for _, qualifier := range []string{"Italy", "06", "Nice"} {
+		if result.matches(qualifier) {
+			t.Errorf("expected %q not to match", qualifier)
+		}
+	}
+}
+
+func TestGeocoderCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocoding", "cache.json")
+
+	g := &Geocoder{CacheFile: path}
+	if err := g.loadCache(); err != nil {
+		t.Fatalf("failed to load missing cache: %v", err)
+	}
+	g.cache[geocodingCacheKey("Nice, France", "")] = geocodingCacheEntry{
+		GeocodingResult: GeocodingResult{Name: "Nice", Country: "France", Latitude: 43.70313, Longitude: 7.26608},
+	}
+	if err := g.saveCache(); err != nil {
+		t.Fatalf("failed to save cache: %v", err)
+	}
+
+	// A new geocoder resolves the location from the file, without a client
+	// to query the API with.
+	cached := &Geocoder{CacheFile: path}
+	loc := LocationConfig{Name: "Home", Query: " nice, france "}
+	if err := cached.Resolve(context.Background(), &loc); err != nil {
+		t.Fatalf("failed to resolve from the cache: %v", err)
+	}
+	if loc.Latitude == nil || loc.Longitude == nil || *loc.Latitude != 43.70313 || *loc.Longitude != 7.26608 {
+		t.Fatalf("unexpected coordinates %v, %v", loc.Latitude, loc.Longitude)
+	}
+	if loc.Geocoded == nil || loc.Geocoded.Country != "France" {
+		t.Errorf("unexpected geocoding result %+v", loc.Geocoded)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The header Prometheus uses to tell targets how long it will wait for the
+// scrape to complete.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// contextCollector binds a collection to the context of a single scrape.
+type contextCollector struct {
+	ctx       context.Context
+	collector OpenMeteoCollector
+}
+
+func (c contextCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.collector.Describe(ch)
+}
+
+func (c contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collector.CollectWithContext(c.ctx, ch)
+}
+
+// metricsHandler serves the metrics of a collection that is cancelled when
+// the scrape is abandoned or times out.
+type metricsHandler struct {
+	collector OpenMeteoCollector
+
+	// Subtracted from the scrape timeout so that there is time left to send
+	// the response.
+	timeoutOffset time.Duration
+
+	// Collectors that are not bound to a scrape, e.g. counters.
+	collectors []prometheus.Collector
+}
+
+// Returns the time left for the scrape according to Prometheus, or zero if
+// no timeout was given.
+func (h *metricsHandler) scrapeTimeout(r *http.Request) time.Duration {
+	raw := r.Header.Get(scrapeTimeoutHeader)
+	if raw == "" {
+		return 0
+	}
+
+	seconds, err := strconv.ParseFloat(raw, 64)
+	if err != nil || seconds <= 0 {
+		level.Warn(logger).Log("msg", "Ignoring invalid scrape timeout header", "value", raw, "err", err)
+		return 0
+	}
+
+	timeout := time.Duration(seconds*float64(time.Second)) - h.timeoutOffset
+	if timeout <= 0 {
+		// Leave the request at least some time rather than failing it outright.
+		timeout = time.Duration(seconds * float64(time.Second) / 2)
+	}
+	return timeout
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if timeout := h.scrapeTimeout(r); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Use a custom registry to avoid generating the go_collector metrics.
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(contextCollector{ctx: ctx, collector: h.collector})
+	registry.MustRegister(h.collectors...)
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
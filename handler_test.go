@@ -0,0 +1,69 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrapeTimeout(t *testing.T) {
+	h := &metricsHandler{timeoutOffset: 500 * time.Millisecond}
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"10", 9500 * time.Millisecond},
+		{"2.5", 2 * time.Second},
+		// Shorter than the offset, half of it is used instead.
+		{"0.4", 200 * time.Millisecond},
+		{"-1", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.header != "" {
+			req.Header.Set(scrapeTimeoutHeader, tt.header)
+		}
+		if got := h.scrapeTimeout(req); got != tt.want {
+			t.Errorf("scrapeTimeout(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMetricsHandlerScrapeTimeout(t *testing.T) {
+	client, fake := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+	fake.SetLatency(5 * time.Second)
+
+	h := &metricsHandler{collector: OpenMeteoCollector{Client: client, Locations: []LocationConfig{*loc}}}
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set(scrapeTimeoutHeader, "0.2")
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	h.ServeHTTP(rec, req)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the scrape did not honour its timeout, took %s", elapsed)
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "openmeteo_location_info") {
+		t.Errorf("expected the metrics that do not need the API, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+}
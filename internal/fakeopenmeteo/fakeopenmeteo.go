@@ -0,0 +1,424 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakeopenmeteo implements an offline stand-in for the Open-Meteo
+// forecast, air quality and geocoding APIs. Responses are built from fixture
+// files so that the exporter can be tested and demonstrated without network
+// access.
+package fakeopenmeteo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ForecastPath   = "/v1/forecast"
+	AirQualityPath = "/v1/air-quality"
+	GeocodingPath  = "/v1/search"
+)
+
+type Options struct {
+	// Directory containing forecast.json, air-quality.json and
+	// geocoding.json. The embedded fixtures are used when empty.
+	FixtureDir string
+
+	// Delay added to every response.
+	Latency time.Duration
+
+	// Maximum number of requests per minute before responding with 429.
+	// Unlimited when zero.
+	RateLimit int
+}
+
+type injectedError struct {
+	status int
+	reason string
+}
+
+// Server is an http.Handler serving the fake APIs.
+type Server struct {
+	fixtures *fixtures
+
+	mu          sync.Mutex
+	latency     time.Duration
+	rateLimit   int
+	windowStart time.Time
+	windowCount int
+	errors      []injectedError
+	requests    int
+}
+
+func New(opts Options) (*Server, error) {
+	f, err := loadFixtures(opts.FixtureDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		fixtures:  f,
+		latency:   opts.Latency,
+		rateLimit: opts.RateLimit,
+	}, nil
+}
+
+// SetLatency changes the delay added to every response.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetRateLimit changes the number of requests allowed per minute and starts
+// a new window.
+func (s *Server) SetRateLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = n
+	s.windowStart = time.Time{}
+	s.windowCount = 0
+}
+
+// InjectError makes the next count requests fail with the given status and
+// reason, in the same format as the real API.
+func (s *Server) InjectError(status int, reason string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.errors = append(s.errors, injectedError{status: status, reason: reason})
+	}
+}
+
+// Requests returns the number of requests served so far.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Reset clears injected errors, the rate limit window and the request count.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = nil
+	s.requests = 0
+	s.windowStart = time.Time{}
+	s.windowCount = 0
+}
+
+// Returns the latency to apply and the error to respond with, if any, for
+// a new request.
+func (s *Server) admit() (time.Duration, *injectedError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+
+	if len(s.errors) > 0 {
+		e := s.errors[0]
+		s.errors = s.errors[1:]
+		return s.latency, &e
+	}
+
+	if s.rateLimit > 0 {
+		now := time.Now()
+		if now.Sub(s.windowStart) >= time.Minute {
+			s.windowStart = now
+			s.windowCount = 0
+		}
+		s.windowCount++
+		if s.windowCount > s.rateLimit {
+			return s.latency, &injectedError{
+				status: http.StatusTooManyRequests,
+				reason: "Minutely API request limit exceeded. Please try again in one minute.",
+			}
+		}
+	}
+
+	return s.latency, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	latency, injected := s.admit()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if injected != nil {
+		writeError(w, injected.status, injected.reason)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Only GET requests are supported")
+		return
+	}
+
+	var resp any
+	var err error
+	switch r.URL.Path {
+	case ForecastPath:
+		resp, err = s.forecast(r.URL.Query(), s.fixtures.forecast, "ForecastVariable")
+	case AirQualityPath:
+		resp, err = s.forecast(r.URL.Query(), s.fixtures.airQuality, "AirQualityVariable")
+	case GeocodingPath:
+		resp, err = s.search(r.URL.Query())
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(resp) //nolint:errcheck
+}
+
+func writeError(w http.ResponseWriter, status int, reason string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": true, "reason": reason}) //nolint:errcheck
+}
+
+// Splits a comma separated list of coordinates, as used to request multiple
+// locations at once.
+func parseCoordinates(values url.Values, name string, limit float64) ([]float64, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, fmt.Errorf("Parameter '%s' is required", name)
+	}
+
+	var coords []float64
+	for _, part := range strings.Split(raw, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot initialize Float from invalid String value %s for key %s", part, name)
+		}
+		if v < -limit || v > limit {
+			label := strings.ToUpper(name[:1]) + name[1:]
+			return nil, fmt.Errorf("%s must be in range of -%g to %g°. Given: %g.", label, limit, limit, v)
+		}
+		coords = append(coords, v)
+	}
+	return coords, nil
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// Snaps a coordinate to the grid of the fake weather model.
+func snap(v float64) float64 {
+	return math.Round(v*20) / 20
+}
+
+func (s *Server) forecast(q url.Values, f *fixture, variableType string) (any, error) {
+	lats, err := parseCoordinates(q, "latitude", 90)
+	if err != nil {
+		return nil, err
+	}
+	lons, err := parseCoordinates(q, "longitude", 180)
+	if err != nil {
+		return nil, err
+	}
+	if len(lats) != len(lons) {
+		return nil, fmt.Errorf("Parameter 'latitude' and 'longitude' must have the same number of elements")
+	}
+
+	var elevations []float64
+	if raw := q.Get("elevation"); raw != "" {
+		for _, part := range splitList(raw) {
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot initialize Float from invalid String value %s for key elevation", part)
+			}
+			elevations = append(elevations, v)
+		}
+		if len(elevations) != 1 && len(elevations) != len(lats) {
+			return nil, fmt.Errorf("Parameter 'elevation' must have the same number of elements as 'latitude'")
+		}
+	}
+
+	timezones := splitList(q.Get("timezone"))
+	if len(timezones) > 1 && len(timezones) != len(lats) {
+		return nil, fmt.Errorf("Parameter 'timezone' must have the same number of elements as 'latitude'")
+	}
+
+	units := unitOptions{
+		temperature:   q.Get("temperature_unit"),
+		windSpeed:     q.Get("wind_speed_unit"),
+		precipitation: q.Get("precipitation_unit"),
+	}
+
+	var responses []map[string]any
+	for i := range lats {
+		tzName := ""
+		if len(timezones) == 1 {
+			tzName = timezones[0]
+		} else if len(timezones) > 1 {
+			tzName = timezones[i]
+		}
+		loc, err := s.resolveTimezone(tzName, lats[i], lons[i])
+		if err != nil {
+			return nil, err
+		}
+
+		resp := map[string]any{
+			"latitude":          snap(lats[i]),
+			"longitude":         snap(lons[i]),
+			"generationtime_ms": f.GenerationtimeMs,
+		}
+
+		elevation := f.Elevation
+		if len(elevations) == 1 {
+			elevation = elevations[0]
+		} else if len(elevations) > 1 {
+			elevation = elevations[i]
+		}
+		resp["elevation"] = elevation
+
+		for _, block := range []string{"current", "hourly", "daily"} {
+			names := splitList(q.Get(block))
+			if len(names) == 0 {
+				continue
+			}
+
+			values, blockUnits, err := f.block(block, names, variableType, units, loc)
+			if err != nil {
+				return nil, err
+			}
+			resp[block] = values
+			resp[block+"_units"] = blockUnits
+
+			if block == "current" {
+				ts, _ := time.ParseInLocation(isoMinute, f.Current["time"].(string), time.UTC)
+				abbreviation, offset := ts.In(loc).Zone()
+				resp["utc_offset_seconds"] = offset
+				resp["timezone_abbreviation"] = abbreviation
+			}
+		}
+
+		if _, ok := resp["utc_offset_seconds"]; !ok {
+			abbreviation, offset := time.Now().In(loc).Zone()
+			resp["utc_offset_seconds"] = offset
+			resp["timezone_abbreviation"] = abbreviation
+		}
+		resp["timezone"] = timezoneName(loc)
+
+		responses = append(responses, resp)
+	}
+
+	if len(responses) == 1 {
+		return responses[0], nil
+	}
+	return responses, nil
+}
+
+func timezoneName(loc *time.Location) string {
+	if loc == time.UTC {
+		return "GMT"
+	}
+	return loc.String()
+}
+
+// Resolves the requested timezone. For "auto" the timezone of the nearest
+// geocoding fixture is used, falling back to GMT.
+func (s *Server) resolveTimezone(name string, lat, lon float64) (*time.Location, error) {
+	switch name {
+	case "", "GMT", "UTC":
+		return time.UTC, nil
+	case "auto":
+		best := 2.0
+		tz := ""
+		for _, r := range s.fixtures.geocoding {
+			if d := math.Hypot(r.Latitude-lat, r.Longitude-lon); d < best {
+				best = d
+				tz = r.Timezone
+			}
+		}
+		if tz == "" {
+			return time.UTC, nil
+		}
+		name = tz
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid timezone")
+	}
+	return loc, nil
+}
+
+func (s *Server) search(q url.Values) (any, error) {
+	name := strings.TrimSpace(q.Get("name"))
+	if name == "" {
+		return nil, fmt.Errorf("Parameter 'name' is required")
+	}
+
+	count := 10
+	if raw := q.Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 100 {
+			return nil, fmt.Errorf("Parameter 'count' must be between 1 and 100")
+		}
+		count = n
+	}
+	countryCode := q.Get("countryCode")
+
+	var results []geocodingResult
+	for _, r := range s.fixtures.geocoding {
+		if countryCode != "" && !strings.EqualFold(r.CountryCode, countryCode) {
+			continue
+		}
+
+		matched := strings.HasPrefix(strings.ToLower(r.Name), strings.ToLower(name))
+		for _, p := range r.Postcodes {
+			matched = matched || p == name
+		}
+		if matched {
+			results = append(results, r)
+		}
+		if len(results) == count {
+			break
+		}
+	}
+
+	resp := map[string]any{"generationtime_ms": 0.5}
+	if len(results) > 0 {
+		resp["results"] = results
+	}
+	return resp, nil
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package fakeopenmeteo
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"time"
+)
+
+//go:embed fixtures/*.json
+var embeddedFixtures embed.FS
+
+const isoMinute = "2006-01-02T15:04"
+
+// A fixture has the same layout as a response of the real API, with all
+// times in UTC. Requests select variables out of it.
+type fixture struct {
+	GenerationtimeMs float64           `json:"generationtime_ms"`
+	Elevation        float64           `json:"elevation"`
+	CurrentUnits     map[string]string `json:"current_units"`
+	Current          map[string]any    `json:"current"`
+	HourlyUnits      map[string]string `json:"hourly_units"`
+	Hourly           map[string]any    `json:"hourly"`
+	DailyUnits       map[string]string `json:"daily_units"`
+	Daily            map[string]any    `json:"daily"`
+}
+
+type geocodingResult struct {
+	Name        string   `json:"name"`
+	Latitude    float64  `json:"latitude"`
+	Longitude   float64  `json:"longitude"`
+	CountryCode string   `json:"country_code"`
+	Timezone    string   `json:"timezone"`
+	Postcodes   []string `json:"postcodes"`
+
+	raw json.RawMessage
+}
+
+func (r geocodingResult) MarshalJSON() ([]byte, error) {
+	return r.raw, nil
+}
+
+type fixtures struct {
+	forecast   *fixture
+	airQuality *fixture
+	geocoding  []geocodingResult
+}
+
+func loadFixtures(dir string) (*fixtures, error) {
+	var fsys fs.FS
+	if dir == "" {
+		sub, err := fs.Sub(embeddedFixtures, "fixtures")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	} else {
+		fsys = os.DirFS(dir)
+	}
+
+	f := &fixtures{}
+	var err error
+	if f.forecast, err = loadFixture(fsys, "forecast.json"); err != nil {
+		return nil, err
+	}
+	if f.airQuality, err = loadFixture(fsys, "air-quality.json"); err != nil {
+		return nil, err
+	}
+
+	data, err := fs.ReadFile(fsys, "geocoding.json")
+	if err != nil {
+		return nil, err
+	}
+	var geocoding struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	if err = json.Unmarshal(data, &geocoding); err != nil {
+		return nil, fmt.Errorf("geocoding.json: %w", err)
+	}
+	for _, raw := range geocoding.Results {
+		r := geocodingResult{raw: raw}
+		if err = json.Unmarshal(raw, &r); err != nil {
+			return nil, fmt.Errorf("geocoding.json: %w", err)
+		}
+		f.geocoding = append(f.geocoding, r)
+	}
+
+	return f, nil
+}
+
+func loadFixture(fsys fs.FS, name string) (*fixture, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &fixture{}
+	if err = json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if _, ok := f.Current["time"].(string); !ok {
+		return nil, fmt.Errorf("%s: current.time is missing", name)
+	}
+	return f, nil
+}
+
+type unitOptions struct {
+	temperature   string
+	windSpeed     string
+	precipitation string
+}
+
+// Converts a value from the unit used in the fixtures to the requested unit,
+// returning the new value and unit.
+func (u unitOptions) convert(value float64, unit string) (float64, string) {
+	switch {
+	case unit == "°C" && u.temperature == "fahrenheit":
+		return value*9/5 + 32, "°F"
+	case unit == "km/h" && u.windSpeed == "mph":
+		return value / 1.609344, "mp/h"
+	case unit == "km/h" && u.windSpeed == "ms":
+		return value / 3.6, "m/s"
+	case unit == "km/h" && u.windSpeed == "kn":
+		return value / 1.852, "kn"
+	case unit == "mm" && u.precipitation == "inch":
+		return value / 25.4, "inch"
+	case unit == "cm" && u.precipitation == "inch":
+		return value / 2.54, "inch"
+	}
+	return value, unit
+}
+
+func (u unitOptions) convertValue(value any, unit string, loc *time.Location) any {
+	switch v := value.(type) {
+	case float64:
+		converted, newUnit := u.convert(v, unit)
+		if newUnit == unit {
+			return v
+		}
+		return math.Round(converted*100) / 100
+	case string:
+		// Timestamps in the fixtures are in UTC.
+		if ts, err := time.ParseInLocation(isoMinute, v, time.UTC); err == nil {
+			return ts.In(loc).Format(isoMinute)
+		}
+	}
+	return value
+}
+
+// Builds the values and units of a current, hourly or daily block with only
+// the requested variables.
+func (f *fixture) block(block string, names []string, variableType string, units unitOptions, loc *time.Location) (map[string]any, map[string]string, error) {
+	var fixtureValues map[string]any
+	var fixtureUnits map[string]string
+	switch block {
+	case "current":
+		fixtureValues, fixtureUnits = f.Current, f.CurrentUnits
+	case "hourly":
+		fixtureValues, fixtureUnits = f.Hourly, f.HourlyUnits
+	case "daily":
+		fixtureValues, fixtureUnits = f.Daily, f.DailyUnits
+	}
+
+	values := map[string]any{}
+	blockUnits := map[string]string{}
+
+	// Always include the time, and the interval for current values.
+	for _, name := range []string{"time", "interval"} {
+		if v, ok := fixtureValues[name]; ok {
+			values[name] = convertAll(v, func(v any) any { return units.convertValue(v, "", loc) })
+			blockUnits[name] = fixtureUnits[name]
+		}
+	}
+
+	for _, name := range names {
+		v, ok := fixtureValues[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("Cannot initialize %s from invalid String value %s for key %s", variableType, name, block)
+		}
+
+		unit := fixtureUnits[name]
+		values[name] = convertAll(v, func(v any) any { return units.convertValue(v, unit, loc) })
+		_, blockUnits[name] = units.convert(0, unit)
+	}
+
+	return values, blockUnits, nil
+}
+
+// Applies fn to a scalar value or to every element of an array.
+func convertAll(value any, fn func(any) any) any {
+	if arr, ok := value.([]any); ok {
+		out := make([]any, len(arr))
+		for i, v := range arr {
+			out[i] = fn(v)
+		}
+		return out
+	}
+	return fn(value)
+}
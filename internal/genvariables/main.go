@@ -0,0 +1,250 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command genvariables generates the variable registries of the exporter
+// from the variable spec file. It is run with go generate.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Go identifiers of the values allowed in the spec.
+var (
+	unitFamilies = map[string]string{
+		"fixed":         "UnitFamilyFixed",
+		"temperature":   "UnitFamilyTemperature",
+		"wind_speed":    "UnitFamilyWindSpeed",
+		"precipitation": "UnitFamilyPrecipitation",
+	}
+	valueTypes = map[string]string{
+		"measurement": "ValueTypeMeasurement",
+		"index":       "ValueTypeIndex",
+		"direction":   "ValueTypeDirection",
+		"code":        "ValueTypeCode",
+		"flag":        "ValueTypeFlag",
+	}
+	regions = map[string]string{
+		"global": "RegionGlobal",
+		"europe": "RegionEurope",
+	}
+	blocks = map[string]string{
+		"current": "BlockCurrent",
+		"hourly":  "BlockHourly",
+		"daily":   "BlockDaily",
+	}
+)
+
+type variableSpec struct {
+	Name        string   `yaml:"name"`
+	Category    string   `yaml:"category"`
+	Unit        string   `yaml:"unit"`
+	UnitFamily  string   `yaml:"unit_family"`
+	ValueType   string   `yaml:"value_type"`
+	Region      string   `yaml:"region"`
+	Blocks      []string `yaml:"blocks"`
+	DeviceClass string   `yaml:"device_class"`
+	Description string   `yaml:"description"`
+}
+
+type spec struct {
+	Weather    []variableSpec `yaml:"weather"`
+	AirQuality []variableSpec `yaml:"airquality"`
+}
+
+// A registry as written to the generated file.
+type registry struct {
+	Var       string
+	API       string
+	Variables []variable
+}
+
+// A variable with all values as Go expressions.
+type variable struct {
+	Name        string
+	Category    string
+	Unit        string
+	UnitFamily  string
+	ValueType   string
+	Region      string
+	Blocks      string
+	DeviceClass string
+	Description string
+}
+
+var fileTemplate = template.Must(template.New("").Parse(`// Code generated by genvariables from {{ .Spec }}. DO NOT EDIT.
+
+package main
+{{ range .Registries }}
+var {{ .Var }} = NewVariableRegistry("{{ .API }}", []Variable{
+{{- range .Variables }}
+	{
+		Name:        {{ .Name }},
+		Category:    {{ .Category }},
+		Unit:        {{ .Unit }},
+		UnitFamily:  {{ .UnitFamily }},
+		ValueType:   {{ .ValueType }},
+		Region:      {{ .Region }},
+		Blocks:      {{ .Blocks }},
+		{{- if .DeviceClass }}
+		DeviceClass: {{ .DeviceClass }},
+		{{- end }}
+		Description: {{ .Description }},
+	},
+{{- end }}
+})
+{{ end }}`))
+
+// Returns the Go identifier of a value of the spec, or of the default value
+// if it is empty.
+func identifier(field, value, fallback string, identifiers map[string]string) (string, error) {
+	if value == "" {
+		value = fallback
+	}
+	id, ok := identifiers[value]
+	if !ok {
+		return "", fmt.Errorf("invalid %s: %s", field, value)
+	}
+	return id, nil
+}
+
+func buildVariable(v variableSpec) (variable, error) {
+	var errs []error
+	if v.Category == "" {
+		errs = append(errs, errors.New("no category provided"))
+	}
+	if v.Description == "" {
+		errs = append(errs, errors.New("no description provided"))
+	}
+
+	unitFamily, err := identifier("unit_family", v.UnitFamily, "fixed", unitFamilies)
+	errs = append(errs, err)
+	valueType, err := identifier("value_type", v.ValueType, "measurement", valueTypes)
+	errs = append(errs, err)
+	region, err := identifier("region", v.Region, "global", regions)
+	errs = append(errs, err)
+
+	names := v.Blocks
+	if len(names) == 0 {
+		names = []string{"current", "hourly"}
+	}
+	var ids []string
+	for _, name := range names {
+		id, err := identifier("block", name, "", blocks)
+		errs = append(errs, err)
+		ids = append(ids, id)
+	}
+
+	var deviceClass string
+	if v.DeviceClass != "" {
+		deviceClass = strconv.Quote(v.DeviceClass)
+	}
+
+	return variable{
+		Name:        strconv.Quote(v.Name),
+		Category:    strconv.Quote(v.Category),
+		Unit:        strconv.Quote(v.Unit),
+		UnitFamily:  unitFamily,
+		ValueType:   valueType,
+		Region:      region,
+		Blocks:      "[]Block{" + strings.Join(ids, ", ") + "}",
+		DeviceClass: deviceClass,
+		Description: strconv.Quote(v.Description),
+	}, errors.Join(errs...)
+}
+
+func buildRegistry(varName, api string, specs []variableSpec) (registry, error) {
+	r := registry{Var: varName, API: api}
+	if len(specs) == 0 {
+		return r, fmt.Errorf("invalid variable spec, no %s variables", api)
+	}
+
+	var errs []error
+	seen := make(map[string]bool)
+	for i, v := range specs {
+		if v.Name == "" {
+			errs = append(errs, fmt.Errorf("invalid %s variable at position %d, no name provided", api, i+1))
+			continue
+		}
+		if seen[v.Name] {
+			errs = append(errs, fmt.Errorf("invalid %s variable, duplicate name: %s", api, v.Name))
+		}
+		seen[v.Name] = true
+
+		built, err := buildVariable(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s variable %s: %w", api, v.Name, err))
+		}
+		r.Variables = append(r.Variables, built)
+	}
+	return r, errors.Join(errs...)
+}
+
+func generate(specFile, outFile string) error {
+	data, err := os.ReadFile(specFile)
+	if err != nil {
+		return err
+	}
+
+	var s spec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err = dec.Decode(&s); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", specFile, err)
+	}
+
+	weather, weatherErr := buildRegistry("weatherVariables", "weather", s.Weather)
+	airQuality, airQualityErr := buildRegistry("airQualityVariables", "airquality", s.AirQuality)
+	if err = errors.Join(weatherErr, airQualityErr); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = fileTemplate.Execute(&buf, map[string]any{
+		"Spec":       specFile,
+		"Registries": []registry{weather, airQuality},
+	})
+	if err != nil {
+		return err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return os.WriteFile(outFile, src, 0o644)
+}
+
+func main() {
+	specFile := flag.String("spec", "variables.yml", "Path to the variable spec file.")
+	outFile := flag.String("out", "variables_gen.go", "Path to the generated Go file.")
+	flag.Parse()
+
+	if err := generate(*specFile, *outFile); err != nil {
+		fmt.Fprintln(os.Stderr, "genvariables:", err)
+		os.Exit(1)
+	}
+}
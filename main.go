@@ -16,89 +16,288 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 
 	kingpin "github.com/alecthomas/kingpin/v2"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
-	"github.com/olekukonko/tablewriter"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/promlog"
 	"github.com/prometheus/common/promlog/flag"
 	"github.com/prometheus/common/version"
 	"github.com/prometheus/exporter-toolkit/web"
 	webflag "github.com/prometheus/exporter-toolkit/web/kingpinflag"
+
+	"github.com/thelande/openmeteo_exporter/internal/fakeopenmeteo"
 )
 
 var (
-	configFile = kingpin.Flag(
+	app = kingpin.New("openmeteo_exporter", "Prometheus exporter for the Open-Meteo weather and air quality APIs.")
+
+	configFile = app.Flag(
 		"config.file",
 		"Path to configuration file.",
 	).Default("config.yaml").String()
-	metricsPath = kingpin.Flag(
+	listVariables = app.Flag(
+		"variables.list",
+		"Deprecated, use the variables command. List the variables available for querying and then exit.",
+	).Hidden().Enum("weather", "airquality")
+	geocodingCacheFile = app.Flag(
+		"geocoding.cache-file",
+		"Path to the file used to cache locations resolved with the Geocoding API. Set to an empty string to disable.",
+	).Default(defaultGeocodingCacheFile()).String()
+	geocodingTimeout = app.Flag(
+		"geocoding.timeout",
+		"The longest a lookup with the Geocoding API may take while loading the configuration.",
+	).Default(defaultGeocodingTimeout.String()).Duration()
+	fakeMode = app.Flag(
+		"fake",
+		"Serve responses from a built-in fake of the Open-Meteo APIs instead of querying the real ones.",
+	).Default("false").Bool()
+	fakeFixturesDir = app.Flag(
+		"fake.fixtures-dir",
+		"Directory with forecast.json, air-quality.json and geocoding.json fixtures for the fake APIs. Uses the built-in fixtures when empty.",
+	).Default("").String()
+	fakeLatency = app.Flag(
+		"fake.latency",
+		"Delay added to every response of the fake APIs.",
+	).Default("0s").Duration()
+	recordDir = app.Flag(
+		"client.record-dir",
+		"Directory to record every Open-Meteo request and response to, for later use with --client.replay-dir.",
+	).Default("").String()
+	replayDir = app.Flag(
+		"client.replay-dir",
+		"Directory of recorded responses to serve instead of querying Open-Meteo.",
+	).Default("").String()
+
+	serveCmd    = app.Command("serve", "Serve the metrics and push them to the configured outputs.").Default()
+	metricsPath = serveCmd.Flag(
 		"web.telemetry-path",
 		"Path under which to expose metrics.",
 	).Default("/metrics").String()
-	listVariables = kingpin.Flag(
-		"variables.list",
-		"List the variables available for querying and then exit.",
-	).Enum("weather", "airquality")
-	webConfig = webflag.AddFlags(kingpin.CommandLine, ":9812")
+	timeoutOffset = serveCmd.Flag(
+		"web.timeout-offset",
+		"Offset to subtract from the timeout set by Prometheus, to allow for the time needed to send the response.",
+	).Default("500ms").Duration()
+	pollInterval = serveCmd.Flag(
+		"poll.interval",
+		"How often to poll Open-Meteo and push the data to the outputs configured in the configuration file.",
+	).Default("1m").Duration()
+	observationTimestamps = serveCmd.Flag(
+		"collector.observation-timestamps",
+		"Attach the observation time reported by Open-Meteo to the weather and air quality metrics instead of using the scrape time.",
+	).Default("false").Bool()
+
+	cacheDir = serveCmd.Flag(
+		"cache.dir",
+		"Directory to persist the last successful response of each location to, to serve them across restarts and when Open-Meteo is unavailable. Disabled when empty.",
+	).Default("").String()
+	cacheMaxAge = serveCmd.Flag(
+		"cache.max-age",
+		"Serve cached responses younger than this instead of querying Open-Meteo. Requires --cache.dir.",
+	).Default("15m").Duration()
+	cacheFallbackMaxAge = serveCmd.Flag(
+		"cache.fallback-max-age",
+		"Serve cached responses younger than this when querying Open-Meteo fails. Requires --cache.dir.",
+	).Default("6h").Duration()
+
+	checkConfigCmd = app.Command("check-config", "Validate the configuration file and print it with all defaults applied.")
+
+	queryCmd      = app.Command("query", "Fetch the current conditions of a location once and print them.")
+	queryLocation = queryCmd.Arg("location", "Name of the location in the configuration file.").Required().String()
+	queryOutput   = queryCmd.Flag("output", "Output format.").Short('o').Default("table").Enum("table", "json", "prometheus")
+
+	dashboardCmd   = app.Command("dashboard", "Print a Grafana dashboard for the configured locations.")
+	dashboardTitle = dashboardCmd.Flag("title", "Title of the dashboard.").Default("Open-Meteo").String()
+	dashboardUID   = dashboardCmd.Flag("uid", "UID of the dashboard.").Default("openmeteo").String()
+
+	rulesCmd = app.Command("rules", "Print a Prometheus rule file with alerts for the configured locations.")
+	rulesJob = rulesCmd.Flag("job", "Name of the Prometheus job that scrapes the exporter.").Default("openmeteo").String()
+
+	variablesCmd    = app.Command("variables", "List the variables available for querying.")
+	variablesAPI    = variablesCmd.Arg("api", "Only list the variables of this API.").Enum("weather", "airquality")
+	variablesOutput = variablesCmd.Flag("output", "Output format.").Short('o').Default("table").Enum("table", "json", "yaml", "markdown", "csv")
+
+	// The listener flags can only be added to the application, they are
+	// used by the serve command.
+	webConfig = webflag.AddFlags(app, ":9812")
 	logger    log.Logger
 )
 
 func main() {
 	promlogConfig := &promlog.Config{}
-	flag.AddFlags(kingpin.CommandLine, promlogConfig)
-	kingpin.CommandLine.UsageWriter(os.Stdout)
-	kingpin.HelpFlag.Short('h')
-	kingpin.Version(version.Print("openmeteo_exporter"))
-	kingpin.Parse()
+	flag.AddFlags(app, promlogConfig)
+	app.UsageWriter(os.Stdout)
+	app.HelpFlag.Short('h')
+	app.Version(version.Print("openmeteo_exporter"))
+	command := kingpin.MustParse(app.Parse(os.Args[1:]))
 
 	logger = promlog.New(promlogConfig)
+
+	var err error
+	switch {
+	case *listVariables != "":
+		level.Warn(logger).Log("msg", "--variables.list is deprecated, use the variables command")
+		err = runVariables(*listVariables, "table")
+	case command == checkConfigCmd.FullCommand():
+		err = runCheckConfig()
+	case command == queryCmd.FullCommand():
+		err = runQuery(*queryLocation, *queryOutput)
+	case command == dashboardCmd.FullCommand():
+		err = runDashboard(*dashboardTitle, *dashboardUID)
+	case command == rulesCmd.FullCommand():
+		err = runRules(*rulesJob)
+	case command == variablesCmd.FullCommand():
+		err = runVariables(*variablesAPI, *variablesOutput)
+	default:
+		err = runServe()
+	}
+
+	if err != nil {
+		level.Error(logger).Log("err", err)
+		os.Exit(1)
+	}
+}
+
+// Builds the client for the mode selected with the flags. The returned
+// function releases the fake APIs, if any.
+func newClient() (*OpenMeteoClient, func(), error) {
+	if *replayDir != "" && (*recordDir != "" || *fakeMode) {
+		return nil, nil, errors.New("--client.replay-dir cannot be combined with --client.record-dir or --fake")
+	}
+
+	client := &OpenMeteoClient{}
+	if *replayDir != "" {
+		client.HTTPClient = &http.Client{Transport: &replayTransport{Dir: *replayDir}}
+		level.Warn(logger).Log("msg", "Replaying recorded Open-Meteo responses", "dir", *replayDir)
+	} else if *recordDir != "" {
+		client.HTTPClient = &http.Client{Transport: &recordingTransport{Dir: *recordDir, Next: http.DefaultTransport}}
+		level.Info(logger).Log("msg", "Recording Open-Meteo responses", "dir", *recordDir)
+	}
+
+	if !*fakeMode {
+		return client, func() {}, nil
+	}
+
+	fake, err := fakeopenmeteo.New(fakeopenmeteo.Options{FixtureDir: *fakeFixturesDir, Latency: *fakeLatency})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load fake API fixtures: %w", err)
+	}
+	fakeServer := httptest.NewServer(fake)
+
+	client.WeatherURL = fakeServer.URL + fakeopenmeteo.ForecastPath
+	client.AirQualityURL = fakeServer.URL + fakeopenmeteo.AirQualityPath
+	client.GeocodingURL = fakeServer.URL + fakeopenmeteo.GeocodingPath
+	level.Warn(logger).Log("msg", "Using fake Open-Meteo APIs, metrics do not reflect real conditions", "url", fakeServer.URL)
+	return client, fakeServer.Close, nil
+}
+
+// Loads the configuration file, resolving locations given by name.
+func loadConfig(client *OpenMeteoClient) (*Config, error) {
+	geocoder := &Geocoder{Client: client, CacheFile: *geocodingCacheFile, Timeout: *geocodingTimeout}
+
+	var config Config
+	if err := config.ReloadConfig(*configFile, geocoder); err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return &config, nil
+}
+
+func runServe() error {
 	level.Info(logger).Log("msg", "Starting openmeteo_exporter", "version", version.Info())
 	level.Info(logger).Log("msg", "Build context", "build_context", version.BuildContext())
 
-	// User requested we list the available variables.
-	if *listVariables != "" {
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Name", "Description"})
-		table.SetRowLine(true)
-		table.SetColWidth(80)
-
-		var vars map[string]string
-		var title string
-		if *listVariables == "weather" {
-			title = "Weather Variables"
-			vars = WeatherVariables
-		} else {
-			title = "Air Quality Variables"
-			vars = AirQualityVariables
-		}
+	client, closeClient, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer closeClient()
+
+	config, err := loadConfig(client)
+	if err != nil {
+		return err
+	}
 
-		fmt.Println(title)
-		for name, desc := range vars {
-			table.Append([]string{name, desc})
+	cache := NewResponseCache()
+	if *cacheDir != "" {
+		cache.Dir = *cacheDir
+		cache.MaxAge = *cacheMaxAge
+		cache.FallbackMaxAge = *cacheFallbackMaxAge
+		if err := cache.Load(config.Locations); err != nil {
+			level.Warn(logger).Log("msg", "Failed to load cached responses", "dir", cache.Dir, "err", err)
 		}
-		table.Render()
+	}
 
-		os.Exit(0)
+	collector := OpenMeteoCollector{
+		Client:                   client,
+		Locations:                config.Locations,
+		UseObservationTimestamps: *observationTimestamps,
+		Status:                   NewStatusTracker(),
+		Cache:                    cache,
 	}
 
-	var config Config
-	if err := config.ReloadConfig(*configFile); err != nil {
-		level.Error(logger).Log("msg", "Failed to load configuration", "err", err)
-		os.Exit(1)
+	var sinks []Sink
+	if config.InfluxDB != nil {
+		sinks = append(sinks, NewInfluxDBSink(config.InfluxDB))
+	}
+	if config.RemoteWrite != nil {
+		sinks = append(sinks, NewRemoteWriteSink(context.Background(), config.RemoteWrite, collector))
+	}
+	if config.MQTT != nil {
+		sink, err := NewMQTTSink(config.MQTT)
+		if err != nil {
+			return fmt.Errorf("failed to create MQTT output: %w", err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if config.OTLP != nil {
+		sink, err := NewOTLPSink(config.OTLP)
+		if err != nil {
+			return fmt.Errorf("failed to create OTLP output: %w", err)
+		}
+		sinks = append(sinks, sink)
 	}
 
-	collector := OpenMeteoCollector{Client: &OpenMeteoClient{}, Locations: config.Locations}
+	collectors := []prometheus.Collector{apiErrorsTotal, requestsDeduplicatedTotal, sinkWritesTotal, remoteWriteSamplesTotal, remoteWriteQueueLength}
+	if config.HasConditions() {
+		var notifiers []Notifier
+		if config.Alerting != nil {
+			for i := range config.Alerting.Webhooks {
+				webhook, err := NewWebhook(&config.Alerting.Webhooks[i])
+				if err != nil {
+					return fmt.Errorf("failed to create webhook: %w", err)
+				}
+				notifiers = append(notifiers, webhook)
+			}
+			for i := range config.Alerting.Alertmanagers {
+				am := NewAlertmanager(&config.Alerting.Alertmanagers[i])
+				go am.Run(context.Background())
+				notifiers = append(notifiers, am)
+			}
+		}
+		engine := NewAlertEngine(config, notifiers)
+		sinks = append(sinks, engine)
+		collectors = append(collectors, engine, alertNotificationsTotal)
+	}
+
+	if len(sinks) > 0 {
+		poller := &Poller{Collector: collector, Interval: *pollInterval, Sinks: sinks}
+		level.Info(logger).Log("msg", "Starting poller", "interval", *pollInterval, "outputs", len(sinks))
+		go poller.Run(context.Background())
+	}
 
-	// Use a custom handler to avoid generating the go_collector metrics.
-	registry := prometheus.NewRegistry()
-	registry.MustRegister(collector)
+	handler := &metricsHandler{
+		collector:     collector,
+		timeoutOffset: *timeoutOffset,
+		collectors:    collectors,
+	}
 
 	landingConfig := web.LandingConfig{
 		Name:        "Open-Meteo Exporter",
@@ -109,20 +308,41 @@ func main() {
 				Address: *metricsPath,
 				Text:    "Metrics",
 			},
+			{
+				Address: "/status",
+				Text:    "Status",
+			},
+			{
+				Address: "/api/v1/locations",
+				Text:    "Current Conditions (JSON)",
+			},
 		},
 	}
 	landingPage, err := web.NewLandingPage(landingConfig)
 	if err != nil {
-		level.Error(logger).Log("err", err)
-		os.Exit(1)
+		return err
 	}
 
-	http.Handle(*metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	http.Handle(*metricsPath, handler)
+	http.Handle("/status", collector.Status)
+	api := &apiHandler{collector: collector}
+	http.HandleFunc("GET /api/v1/locations", api.ServeLocations)
+	http.HandleFunc("GET /api/v1/locations/{name}", api.ServeLocation)
 	http.Handle("/", landingPage)
 
 	srv := &http.Server{}
 	if err := web.ListenAndServe(srv, webConfig, logger); err != nil {
-		level.Error(logger).Log("msg", "HTTP listener stopped", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("HTTP listener stopped: %w", err)
+	}
+	return nil
+}
+
+// Store the geocoding cache in the user's cache directory when one is
+// available, otherwise in the working directory.
+func defaultGeocodingCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "geocoding_cache.json"
 	}
+	return filepath.Join(dir, "openmeteo_exporter", "geocoding_cache.json")
 }
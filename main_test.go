@@ -0,0 +1,67 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-kit/log"
+
+	"github.com/thelande/openmeteo_exporter/internal/fakeopenmeteo"
+)
+
+func TestMain(m *testing.M) {
+	logger = log.NewNopLogger()
+	os.Exit(m.Run())
+}
+
+// Starts the fake Open-Meteo APIs with the built-in fixtures and returns a
+// client querying them.
+func newFakeClient(t *testing.T) (*OpenMeteoClient, *fakeopenmeteo.Server) {
+	t.Helper()
+
+	fake, err := fakeopenmeteo.New(fakeopenmeteo.Options{})
+	if err != nil {
+		t.Fatalf("failed to create fake server: %v", err)
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := &OpenMeteoClient{
+		WeatherURL:    server.URL + fakeopenmeteo.ForecastPath,
+		AirQualityURL: server.URL + fakeopenmeteo.AirQualityPath,
+		GeocodingURL:  server.URL + fakeopenmeteo.GeocodingPath,
+	}
+	return client, fake
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+// Returns a location at Nice with the given variables.
+func testLocation(name string, weather, airQuality []string) LocationConfig {
+	loc := LocationConfig{Name: name, Latitude: float64Ptr(43.7), Longitude: float64Ptr(7.27)}
+	if weather != nil {
+		loc.Weather = &WeatherConfig{Variables: weather}
+	}
+	if airQuality != nil {
+		loc.AirQuality = &AirQualityConfig{Variables: airQuality}
+	}
+	return loc
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var sinkWritesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sink_writes_total",
+		Help:      "The number of writes of polled data to each output, by result.",
+	},
+	[]string{"sink", "result"},
+)
+
+// Sink receives the data fetched by every poll of the Poller, e.g. to push
+// it to another system.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, data []*LocationData) error
+}
+
+// Poller periodically fetches the current conditions of all locations and
+// hands them to the sinks.
+type Poller struct {
+	Collector OpenMeteoCollector
+	Interval  time.Duration
+	Sinks     []Sink
+}
+
+// Run polls immediately and then on every interval until ctx is done.
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Poller) poll(ctx context.Context) {
+	// Never let a poll overlap with the next one.
+	ctx, cancel := context.WithTimeout(ctx, p.Interval)
+	defer cancel()
+
+	data := p.Collector.Fetch(ctx)
+
+	var wg sync.WaitGroup
+	for _, sink := range p.Sinks {
+		wg.Add(1)
+		go func(sink Sink) {
+			defer wg.Done()
+
+			if err := sink.Write(ctx, data); err != nil {
+				sinkWritesTotal.WithLabelValues(sink.Name(), "error").Inc()
+				level.Error(logger).Log("msg", "Failed to write to output", "sink", sink.Name(), "err", err)
+				return
+			}
+			sinkWritesTotal.WithLabelValues(sink.Name(), "success").Inc()
+			level.Debug(logger).Log("msg", "Wrote to output", "sink", sink.Name(), "locations", len(data))
+		}(sink)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,328 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is returned when Open-Meteo responds with an error, either with
+// a non-2XX status or an error object in the body.
+type APIError struct {
+	StatusCode int
+	Reason     string
+}
+
+func (e *APIError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("open-meteo returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("open-meteo returned status %d: %s", e.StatusCode, e.Reason)
+}
+
+// Allows errors.Is(err, ErrNon2XXResponse) to keep working.
+func (e *APIError) Is(target error) bool {
+	return target == ErrNon2XXResponse && (e.StatusCode < 200 || e.StatusCode > 299)
+}
+
+// Builds an APIError from the body of a response, using the reason of the
+// error object when the body contains one.
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+
+	var errResp errorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Reason != "" {
+		apiErr.Reason = errResp.Reason
+	} else {
+		reason := strings.TrimSpace(string(body))
+		if len(reason) > 200 {
+			reason = reason[:200] + "..."
+		}
+		apiErr.Reason = reason
+	}
+	return apiErr
+}
+
+// DecodeError is returned when a response body cannot be decoded.
+type DecodeError struct {
+	API   string
+	Field string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("failed to decode %s response field %s: %v", e.API, e.Field, e.Err)
+	}
+	return fmt.Sprintf("failed to decode %s response: %v", e.API, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Value is a single value of a variable. Most variables are numbers, but
+// the API returns null when no data is available and ISO8601 strings for
+// some variables, such as sunrise, which are converted to seconds since the
+// epoch.
+type Value struct {
+	Number float64
+	Valid  bool
+
+	// The original value when the API returned a string, and whether it was
+	// parsed as a timestamp.
+	Text   string
+	IsTime bool
+}
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	*v = Value{}
+
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case bytes.Equal(data, []byte("true")):
+		v.Number, v.Valid = 1, true
+		return nil
+	case bytes.Equal(data, []byte("false")):
+		v.Number, v.Valid = 0, true
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		if err := json.Unmarshal(data, &v.Text); err != nil {
+			return err
+		}
+		v.Number, v.Valid, v.IsTime = parseTextValue(v.Text)
+		return nil
+	}
+
+	n, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("unsupported value %s", data)
+	}
+	v.Number, v.Valid = n, true
+	return nil
+}
+
+// Layouts of the timestamps returned by the API, which are always in the
+// timezone of the response.
+var timestampLayouts = []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02"}
+
+// Timestamps are parsed as UTC, BaseResponse.localizeTimestamps corrects
+// them once the offset of the response is known.
+func parseTextValue(text string) (float64, bool, bool) {
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		return n, true, false
+	}
+	for _, layout := range timestampLayouts {
+		if ts, err := time.Parse(layout, text); err == nil {
+			return float64(ts.Unix()), true, true
+		}
+	}
+	return 0, false, false
+}
+
+type ResponseUnits struct {
+	Time      string
+	Interval  string
+	Variables map[string]string
+}
+
+func (u *ResponseUnits) UnmarshalJSON(data []byte) error {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	u.Variables = make(map[string]string)
+	for name, unit := range raw {
+		var s string
+		switch unit := unit.(type) {
+		case nil:
+		case string:
+			s = unit
+		default:
+			s = fmt.Sprint(unit)
+		}
+
+		switch name {
+		case "time":
+			u.Time = s
+		case "interval":
+			u.Interval = s
+		default:
+			u.Variables[name] = s
+		}
+	}
+	return nil
+}
+
+type ResponseValues struct {
+	Time      string
+	Interval  int
+	Variables map[string]Value
+}
+
+func (v *ResponseValues) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	v.Variables = make(map[string]Value)
+	for name, value := range raw {
+		var parsed Value
+		if err := parsed.UnmarshalJSON(value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		switch name {
+		case "time":
+			if !parsed.IsTime {
+				return fmt.Errorf("time: expected a timestamp, got %s", value)
+			}
+			v.Time = parsed.Text
+		case "interval":
+			if !parsed.Valid || parsed.Text != "" {
+				return fmt.Errorf("interval: expected a number of seconds, got %s", value)
+			}
+			v.Interval = int(parsed.Number)
+		default:
+			v.Variables[name] = parsed
+		}
+	}
+	return nil
+}
+
+// ResponseSeries holds the hourly or daily values of variables.
+type ResponseSeries struct {
+	Time      []string
+	Variables map[string][]Value
+}
+
+func (s *ResponseSeries) UnmarshalJSON(data []byte) error {
+	var raw map[string][]Value
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	s.Variables = make(map[string][]Value)
+	for name, values := range raw {
+		if name == "time" {
+			for _, ts := range values {
+				s.Time = append(s.Time, ts.Text)
+			}
+			continue
+		}
+		s.Variables[name] = values
+	}
+	return nil
+}
+
+type BaseResponse struct {
+	Latitude             float64        `json:"latitude"`
+	Longitude            float64        `json:"longitude"`
+	GenerationtimeMs     float32        `json:"generationtime_ms"`
+	UTCOffsetSeconds     int            `json:"utc_offset_seconds"`
+	Timezone             string         `json:"timezone"`
+	TimezoneAbbreviation string         `json:"timezone_abbreviation"`
+	CurrentUnits         ResponseUnits  `json:"current_units"`
+	Current              ResponseValues `json:"current"`
+	HourlyUnits          ResponseUnits  `json:"hourly_units"`
+	Hourly               ResponseSeries `json:"hourly"`
+	DailyUnits           ResponseUnits  `json:"daily_units"`
+	Daily                ResponseSeries `json:"daily"`
+
+	// The body the response was decoded from, kept to persist it.
+	Raw []byte `json:"-"`
+}
+
+// Returns the time of the current values. The API reports it as local time
+// in the timezone of the response.
+func (r *BaseResponse) ObservationTime() (time.Time, error) {
+	zone := time.FixedZone(r.TimezoneAbbreviation, r.UTCOffsetSeconds)
+	return time.ParseInLocation("2006-01-02T15:04", r.Current.Time, zone)
+}
+
+// Converts timestamp values from local time to seconds since the epoch.
+func (r *BaseResponse) localizeTimestamps() {
+	offset := float64(r.UTCOffsetSeconds)
+	for name, v := range r.Current.Variables {
+		if v.IsTime {
+			v.Number -= offset
+			r.Current.Variables[name] = v
+		}
+	}
+	for _, series := range []*ResponseSeries{&r.Hourly, &r.Daily} {
+		for _, values := range series.Variables {
+			for i := range values {
+				if values[i].IsTime {
+					values[i].Number -= offset
+				}
+			}
+		}
+	}
+}
+
+type WeatherResponse struct {
+	BaseResponse
+	Elevation float64 `json:"elevation"`
+}
+
+// Top level fields of an error returned by the API, e.g.
+// {"error": true, "reason": "Latitude must be in range of -90 to 90°."}.
+type errorResponse struct {
+	Error  bool   `json:"error"`
+	Reason string `json:"reason"`
+}
+
+// Decodes the body of a response of any of the APIs into v. Error objects
+// returned with a 2XX status are reported as errors as well.
+func decodeResponse(api string, body []byte, v any) error {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return &DecodeError{API: api, Err: errors.New("empty response body")}
+	}
+	if trimmed[0] != '{' {
+		return &DecodeError{API: api, Err: errors.New("expected a JSON object")}
+	}
+
+	var errResp errorResponse
+	if err := json.Unmarshal(trimmed, &errResp); err == nil && errResp.Error {
+		return &APIError{StatusCode: http.StatusOK, Reason: errResp.Reason}
+	}
+
+	if err := json.Unmarshal(trimmed, v); err != nil {
+		decodeErr := &DecodeError{API: api, Err: err}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			decodeErr.Field = typeErr.Field
+		}
+		return decodeErr
+	}
+
+	if l, ok := v.(interface{ localizeTimestamps() }); ok {
+		l.localizeTimestamps()
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueUnmarshalJSON(t *testing.T) {
+	// 2024-06-01T05:51 read as UTC, before the offset of the response is
+	// applied.
+	sunrise := float64(time.Date(2024, 6, 1, 5, 51, 0, 0, time.UTC).Unix())
+
+	tests := []struct {
+		json string
+		want Value
+		err  bool
+	}{
+		{json: `null`, want: Value{}},
+		{json: `12`, want: Value{Number: 12, Valid: true}},
+		{json: `-3.5`, want: Value{Number: -3.5, Valid: true}},
+		{json: `1e3`, want: Value{Number: 1000, Valid: true}},
+		{json: ` 7 `, want: Value{Number: 7, Valid: true}},
+		{json: `true`, want: Value{Number: 1, Valid: true}},
+		{json: `false`, want: Value{Number: 0, Valid: true}},
+		{json: `"12.5"`, want: Value{Number: 12.5, Valid: true, Text: "12.5"}},
+		{json: `"2024-06-01T05:51"`, want: Value{Number: sunrise, Valid: true, Text: "2024-06-01T05:51", IsTime: true}},
+		{json: `"2024-06-01T05:51:30"`, want: Value{Number: sunrise + 30, Valid: true, Text: "2024-06-01T05:51:30", IsTime: true}},
+		{json: `"2024-06-01"`, want: Value{Number: sunrise - 5*3600 - 51*60, Valid: true, Text: "2024-06-01", IsTime: true}},
+		// Text that is neither a number nor a timestamp has no value.
+		{json: `"n/a"`, want: Value{Text: "n/a"}},
+		{json: `{}`, err: true},
+		{json: `[1]`, err: true},
+		{json: `"unterminated`, err: true},
+	}
+	for _, tt := range tests {
+		var v Value
+		err := v.UnmarshalJSON([]byte(tt.json))
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %+v", tt.json, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.json, err)
+			continue
+		}
+		if v != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.json, v, tt.want)
+		}
+	}
+}
+
+const testWeatherBody = `{
+	"latitude": 43.7,
+	"longitude": 7.25,
+	"generationtime_ms": 0.05,
+	"utc_offset_seconds": 7200,
+	"timezone": "Europe/Paris",
+	"timezone_abbreviation": "CEST",
+	"elevation": 21,
+	"current_units": {"time": "iso8601", "interval": "seconds", "temperature_2m": "°C", "is_day": "", "weather_code": "wmo code", "cloud_cover": null, "snow_depth": 1},
+	"current": {"time": "2024-06-01T14:00", "interval": 900, "temperature_2m": 18.5, "is_day": true, "weather_code": 3, "cloud_cover": null, "sunrise": "2024-06-01T05:51"},
+	"daily_units": {"time": "iso8601", "sunrise": "iso8601", "temperature_2m_max": "°C"},
+	"daily": {"time": ["2024-06-01", "2024-06-02"], "sunrise": ["2024-06-01T05:51", null], "temperature_2m_max": [22, 21.5]}
+}`
+
+func TestDecodeResponse(t *testing.T) {
+	var resp WeatherResponse
+	if err := decodeResponse("weather", []byte(testWeatherBody), &resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.Latitude != 43.7 || resp.Elevation != 21 || resp.UTCOffsetSeconds != 7200 || resp.Timezone != "Europe/Paris" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	units := resp.CurrentUnits
+	if units.Time != "iso8601" || units.Interval != "seconds" || units.Variables["temperature_2m"] != "°C" ||
+		units.Variables["cloud_cover"] != "" || units.Variables["snow_depth"] != "1" {
+		t.Errorf("unexpected units %+v", units)
+	}
+
+	// The timestamps of the API are local time, 14:00 CEST.
+	observed, err := resp.ObservationTime()
+	if err != nil || !observed.Equal(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected observation time %v, %v", observed, err)
+	}
+	if resp.Current.Interval != 900 {
+		t.Errorf("unexpected interval %d", resp.Current.Interval)
+	}
+
+	// Timestamp values are corrected to UTC with the offset of the response,
+	// 05:51 CEST being 03:51 UTC.
+	sunrise := float64(time.Date(2024, 6, 1, 3, 51, 0, 0, time.UTC).Unix())
+	current := map[string]Value{
+		"temperature_2m": {Number: 18.5, Valid: true},
+		"is_day":         {Number: 1, Valid: true},
+		"weather_code":   {Number: 3, Valid: true},
+		"cloud_cover":    {},
+		"sunrise":        {Number: sunrise, Valid: true, Text: "2024-06-01T05:51", IsTime: true},
+	}
+	if len(resp.Current.Variables) != len(current) {
+		t.Errorf("unexpected variables %+v", resp.Current.Variables)
+	}
+	for name, want := range current {
+		if got := resp.Current.Variables[name]; got != want {
+			t.Errorf("current %s: got %+v, want %+v", name, got, want)
+		}
+	}
+
+	daily := resp.Daily
+	if strings.Join(daily.Time, ",") != "2024-06-01,2024-06-02" {
+		t.Errorf("unexpected daily times %v", daily.Time)
+	}
+	if got := daily.Variables["sunrise"]; len(got) != 2 || got[0].Number != sunrise || got[1].Valid {
+		t.Errorf("unexpected daily sunrise %+v", got)
+	}
+	if got := daily.Variables["temperature_2m_max"]; len(got) != 2 || got[0].Number != 22 || got[1].Number != 21.5 {
+		t.Errorf("unexpected daily maximum %+v", got)
+	}
+}
+
+func TestDecodeResponseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		field string
+		msg   string
+	}{
+		{"empty body", "", "", "empty response body"},
+		{"blank body", " \n", "", "empty response body"},
+		{"array", `[]`, "", "expected a JSON object"},
+		{"string", `"ok"`, "", "expected a JSON object"},
+		{"null", `null`, "", "expected a JSON object"},
+		{"truncated", `{"latitude": 43.7,`, "", "unexpected end of JSON input"},
+		{"string latitude", `{"latitude": "43.7"}`, "latitude", "cannot unmarshal string"},
+		{"current values", `{"current": {"temperature_2m": {"value": 18.5}}}`, "", "temperature_2m: unsupported value"},
+		{"current time", `{"current": {"time": 1717243200}}`, "", "time: expected a timestamp"},
+		{"current interval", `{"current": {"interval": "15 minutes"}}`, "", "interval: expected a number of seconds"},
+		{"current array", `{"current": [18.5]}`, "", "cannot unmarshal array"},
+		{"units", `{"current_units": ["°C"]}`, "", "cannot unmarshal array"},
+		{"series", `{"hourly": {"temperature_2m": 18.5}}`, "temperature_2m", "cannot unmarshal number"},
+	}
+	for _, tt := range tests {
+		var resp WeatherResponse
+		err := decodeResponse("weather", []byte(tt.body), &resp)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Errorf("%s: expected a decode error, got %v", tt.name, err)
+			continue
+		}
+		if decodeErr.API != "weather" || decodeErr.Field != tt.field || !strings.Contains(decodeErr.Err.Error(), tt.msg) {
+			t.Errorf("%s: got %q in field %q, want %q in field %q", tt.name, decodeErr.Err, decodeErr.Field, tt.msg, tt.field)
+		}
+	}
+}
+
+func TestDecodeResponseErrorObject(t *testing.T) {
+	// The API may report an error with a 2XX status.
+	body := `{"error": true, "reason": "Cannot initialize WeatherVariable from invalid String value tempeture_2m for key current"}`
+	var resp WeatherResponse
+	err := decodeResponse("weather", []byte(body), &resp)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an API error, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusOK || !strings.HasPrefix(apiErr.Reason, "Cannot initialize WeatherVariable") {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+	if errors.Is(err, ErrNon2XXResponse) {
+		t.Error("an error object with a 2XX status is not a non-2XX response")
+	}
+
+	// An error field that is false is not an error.
+	if err := decodeResponse("weather", []byte(`{"error": false, "latitude": 43.7}`), &resp); err != nil || resp.Latitude != 43.7 {
+		t.Errorf("unexpected result %v, %+v", err, resp)
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"error": true, "reason": "Latitude must be in range of -90 to 90°."}`, "Latitude must be in range of -90 to 90°."},
+		{"  Bad Gateway\n", "Bad Gateway"},
+		{strings.Repeat("x", 300), strings.Repeat("x", 200) + "..."},
+	}
+	for _, tt := range tests {
+		err := newAPIError(http.StatusBadRequest, []byte(tt.body))
+		if err.StatusCode != http.StatusBadRequest || err.Reason != tt.want {
+			t.Errorf("unexpected error %+v", err)
+		}
+		if !errors.Is(err, ErrNon2XXResponse) {
+			t.Errorf("expected %v to be a non-2XX response", err)
+		}
+	}
+}
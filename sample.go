@@ -0,0 +1,115 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+// Sample is the current value of a single variable for a location, as
+// exported by the collectors.
+type Sample struct {
+	Location string
+	API      string
+	Variable string
+
+	// The unit as returned by the API and as used in the metric name.
+	Unit       string
+	UnitSuffix string
+	MetricName string
+
+	Value float64
+
+	// The time of the observation, zero if the API did not provide one.
+	Time time.Time
+}
+
+// LocationData holds the outcome of fetching the current conditions of a
+// location from each of the configured APIs.
+type LocationData struct {
+	Location *LocationConfig
+
+	Weather       *WeatherResponse
+	WeatherErr    error
+	AirQuality    *BaseResponse
+	AirQualityErr error
+
+	// Whether the responses were fetched from Open-Meteo or served from the
+	// cache. A response served because the request failed comes with the
+	// error of the request.
+	WeatherSource    ResponseSource
+	AirQualitySource ResponseSource
+
+	FetchedAt time.Time
+}
+
+// Returns the response used to describe the location. Both APIs are queried
+// with the same timezone, so either response can be used.
+func (d *LocationData) Base() *BaseResponse {
+	if d.Weather != nil {
+		return &d.Weather.BaseResponse
+	}
+	return d.AirQuality
+}
+
+// Samples returns the current values of all variables that were fetched.
+func (d *LocationData) Samples() []Sample {
+	if d == nil {
+		return nil
+	}
+
+	var samples []Sample
+	if d.Weather != nil {
+		samples = append(samples, variableSamples(d.Location, "weather", d.Location.Weather.Variables, &d.Weather.BaseResponse)...)
+	}
+	if d.AirQuality != nil {
+		samples = append(samples, variableSamples(d.Location, "airquality", d.Location.AirQuality.Variables, d.AirQuality)...)
+	}
+	return samples
+}
+
+// Builds the samples of the requested variables of a response. Variables
+// without a value are skipped.
+func variableSamples(loc *LocationConfig, api string, names []string, resp *BaseResponse) []Sample {
+	ts, err := resp.ObservationTime()
+	if err != nil {
+		ts = time.Time{}
+	}
+
+	var samples []Sample
+	for _, name := range names {
+		value, ok := resp.Current.Variables[name]
+		if !ok || !value.Valid {
+			level.Warn(logger).Log("msg", "No value for metric returned", "location", loc.Name, "name", name, "value", value.Text)
+			continue
+		}
+
+		unit := resp.CurrentUnits.Variables[name]
+		samples = append(samples, Sample{
+			Location:   loc.Name,
+			API:        api,
+			Variable:   name,
+			Unit:       unit,
+			UnitSuffix: metricUnitSuffix(api, unit),
+			MetricName: variableMetricName(api, name, unit),
+			Value:      value.Number,
+			Time:       ts,
+		})
+	}
+	return samples
+}
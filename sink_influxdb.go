@@ -0,0 +1,253 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+const (
+	defaultInfluxDBMeasurement = "openmeteo"
+	defaultInfluxDBBatchSize   = 5000
+	defaultInfluxDBMaxRetries  = 3
+	defaultInfluxDBTimeout     = 10 * time.Second
+)
+
+type InfluxDBConfig struct {
+	// Base URL of the server, e.g. http://localhost:8086.
+	URL         string        `yaml:"url,omitempty"`
+	Org         string        `yaml:"org,omitempty"`
+	Bucket      string        `yaml:"bucket,omitempty"`
+	Token       string        `yaml:"token,omitempty"`
+	TokenFile   string        `yaml:"token_file,omitempty"`
+	Measurement string        `yaml:"measurement,omitempty"`
+	BatchSize   int           `yaml:"batch_size,omitempty"`
+	MaxRetries  *int          `yaml:"max_retries,omitempty"`
+	Timeout     time.Duration `yaml:"timeout,omitempty"`
+}
+
+func (c *InfluxDBConfig) Validate() error {
+	var errs []error
+	if len(c.URL) == 0 {
+		errs = append(errs, errors.New("invalid influxdb config, no url provided"))
+	} else if _, err := url.Parse(c.URL); err != nil {
+		errs = append(errs, fmt.Errorf("invalid influxdb config, bad url: %w", err))
+	}
+
+	if len(c.Bucket) == 0 {
+		errs = append(errs, errors.New("invalid influxdb config, no bucket provided"))
+	}
+
+	if len(c.Token) != 0 && len(c.TokenFile) != 0 {
+		errs = append(errs, errors.New("invalid influxdb config, only one of token and token_file may be set"))
+	}
+
+	if len(c.Measurement) == 0 {
+		c.Measurement = defaultInfluxDBMeasurement
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultInfluxDBBatchSize
+	}
+	if c.MaxRetries == nil {
+		retries := defaultInfluxDBMaxRetries
+		c.MaxRetries = &retries
+	} else if *c.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("invalid influxdb config, max_retries must not be negative: %d", *c.MaxRetries))
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultInfluxDBTimeout
+	}
+
+	return errors.Join(errs...)
+}
+
+// InfluxDBSink writes the polled samples to InfluxDB using the line
+// protocol and the v2 write API, which InfluxDB 1.8+ supports as well.
+type InfluxDBSink struct {
+	Config *InfluxDBConfig
+	Client *http.Client
+}
+
+func NewInfluxDBSink(cfg *InfluxDBConfig) *InfluxDBSink {
+	return &InfluxDBSink{Config: cfg, Client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+func (s *InfluxDBSink) Name() string {
+	return "influxdb"
+}
+
+func (s *InfluxDBSink) writeURL() (string, error) {
+	u, err := url.Parse(s.Config.URL)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(u.Path, "/api/v2/write") {
+		u = u.JoinPath("api", "v2", "write")
+	}
+
+	q := u.Query()
+	if s.Config.Org != "" {
+		q.Set("org", s.Config.Org)
+	}
+	q.Set("bucket", s.Config.Bucket)
+	q.Set("precision", "s")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (s *InfluxDBSink) token() (string, error) {
+	if s.Config.TokenFile != "" {
+		data, err := os.ReadFile(s.Config.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return s.Config.Token, nil
+}
+
+func (s *InfluxDBSink) Write(ctx context.Context, data []*LocationData) error {
+	var lines []string
+	for _, d := range data {
+		for _, sample := range d.Samples() {
+			ts := sample.Time
+			if ts.IsZero() {
+				ts = d.FetchedAt
+			}
+			lines = append(lines, influxLine(s.Config.Measurement, &sample, ts))
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	writeURL, err := s.writeURL()
+	if err != nil {
+		return err
+	}
+	token, err := s.token()
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(lines); start += s.Config.BatchSize {
+		end := min(start+s.Config.BatchSize, len(lines))
+		body := strings.Join(lines[start:end], "\n") + "\n"
+		if err = s.writeBatch(ctx, writeURL, token, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sends a single batch, retrying with exponential backoff on network errors,
+// rate limiting and server errors.
+func (s *InfluxDBSink) writeBatch(ctx context.Context, writeURL, token, body string) error {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, writeURL, token, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= *s.Config.MaxRetries {
+			return err
+		}
+
+		level.Warn(logger).Log("msg", "Failed to write to InfluxDB, retrying", "attempt", attempt+1, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// Returns whether the request may be retried along with the error.
+func (s *InfluxDBSink) post(ctx context.Context, writeURL, token, body string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, writeURL, strings.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body) //nolint:errcheck
+		return false, nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("influxdb returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// Formats a sample as a line of the InfluxDB line protocol, e.g.
+// openmeteo,api=weather,location=Nice,unit=celsius,variable=temperature_2m value=18.4 1717243200
+func influxLine(measurement string, sample *Sample, ts time.Time) string {
+	var b strings.Builder
+	b.WriteString(influxMeasurementEscaper.Replace(measurement))
+
+	// Tags are sorted by key, as recommended for write performance. Empty
+	// tag values are not allowed.
+	tags := [][2]string{
+		{"api", sample.API},
+		{"location", sample.Location},
+		{"unit", sample.UnitSuffix},
+		{"variable", sample.Variable},
+	}
+	for _, tag := range tags {
+		if tag[1] == "" {
+			continue
+		}
+		b.WriteString(",")
+		b.WriteString(tag[0])
+		b.WriteString("=")
+		b.WriteString(influxTagEscaper.Replace(tag[1]))
+	}
+
+	b.WriteString(" value=")
+	b.WriteString(strconv.FormatFloat(sample.Value, 'f', -1, 64))
+	b.WriteString(" ")
+	b.WriteString(strconv.FormatInt(ts.Unix(), 10))
+	return b.String()
+}
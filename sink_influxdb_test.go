@@ -0,0 +1,143 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInfluxLine(t *testing.T) {
+	ts := time.Unix(1717243200, 0)
+	tests := []struct {
+		sample Sample
+		want   string
+	}{
+		{
+			Sample{Location: "Nice", API: "weather", Variable: "temperature_2m", UnitSuffix: "celsius", Value: 18.4},
+			"openmeteo,api=weather,location=Nice,unit=celsius,variable=temperature_2m value=18.4 1717243200",
+		},
+		{
+			// Tags without a value are left out.
+			Sample{Location: "Nice", API: "weather", Variable: "is_day", Value: 1},
+			"openmeteo,api=weather,location=Nice,variable=is_day value=1 1717243200",
+		},
+		{
+			Sample{Location: "Saint Tropez, Var=83", API: "airquality", Variable: "pm2_5", UnitSuffix: "ug_per_m3", Value: 0.000012},
+			`openmeteo,api=airquality,location=Saint\ Tropez\,\ Var\=83,unit=ug_per_m3,variable=pm2_5 value=0.000012 1717243200`,
+		},
+	}
+	for _, tt := range tests {
+		if got := influxLine("openmeteo", &tt.sample, ts); got != tt.want {
+			t.Errorf("influxLine(%+v) =\n%s\nwant\n%s", tt.sample, got, tt.want)
+		}
+	}
+
+	if got := influxLine("open meteo,wx", &tests[1].sample, ts); !strings.HasPrefix(got, `open\ meteo\,wx,`) {
+		t.Errorf("measurement is not escaped: %s", got)
+	}
+}
+
+// An InfluxDB write endpoint recording the requests it receives.
+type testInfluxDB struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (s *testInfluxDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+	if s.status != 0 {
+		http.Error(w, "partial write: field type conflict", s.status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestInfluxDBSinkWrite(t *testing.T) {
+	client, _ := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m", "wind_speed_10m"}, []string{"pm2_5"}))
+	collector := OpenMeteoCollector{Client: client, Locations: []LocationConfig{*loc}}
+	data := collector.Fetch(context.Background())
+
+	influx := &testInfluxDB{}
+	server := httptest.NewServer(influx)
+	defer server.Close()
+
+	cfg := &InfluxDBConfig{URL: server.URL, Org: "home", Bucket: "weather", Token: "secret", BatchSize: 2}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	if err := NewInfluxDBSink(cfg).Write(context.Background(), data); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	// Three samples in batches of two.
+	if len(influx.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(influx.requests))
+	}
+	req := influx.requests[0]
+	if req.URL.Path != "/api/v2/write" || req.URL.Query().Get("bucket") != "weather" || req.URL.Query().Get("org") != "home" || req.URL.Query().Get("precision") != "s" {
+		t.Errorf("unexpected write URL %s", req.URL)
+	}
+	if auth := req.Header.Get("Authorization"); auth != "Token secret" {
+		t.Errorf("unexpected Authorization header %q", auth)
+	}
+
+	lines := strings.Split(strings.TrimSpace(strings.Join(influx.bodies, "")), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %q", len(lines), lines)
+	}
+	want := "openmeteo,api=weather,location=Nice,unit=fahrenheit,variable=temperature_2m value=65.12 "
+	if !strings.HasPrefix(lines[0], want) {
+		t.Errorf("unexpected first line %q, want prefix %q", lines[0], want)
+	}
+}
+
+func TestInfluxDBSinkWriteRejected(t *testing.T) {
+	influx := &testInfluxDB{status: http.StatusBadRequest}
+	server := httptest.NewServer(influx)
+	defer server.Close()
+
+	cfg := &InfluxDBConfig{URL: server.URL, Bucket: "weather"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	loc := testLocation("Nice", []string{"temperature_2m"}, nil)
+	data := []*LocationData{{Location: &loc, Weather: &WeatherResponse{}}}
+	data[0].Weather.Current.Variables = map[string]Value{"temperature_2m": {Number: 18.4, Valid: true}}
+	data[0].Weather.CurrentUnits.Variables = map[string]string{"temperature_2m": "°C"}
+
+	err := NewInfluxDBSink(cfg).Write(context.Background(), data)
+	if err == nil || !strings.Contains(err.Error(), "field type conflict") {
+		t.Fatalf("expected the reason of the rejection, got %v", err)
+	}
+	// Client errors are not retried.
+	if len(influx.requests) != 1 {
+		t.Errorf("expected 1 request, got %d", len(influx.requests))
+	}
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/version"
+)
+
+const (
+	defaultMQTTClientID        = "openmeteo_exporter"
+	defaultMQTTTopicPrefix     = "openmeteo"
+	defaultMQTTTimeout         = 10 * time.Second
+	defaultHomeAssistantPrefix = "homeassistant"
+)
+
+type HomeAssistantConfig struct {
+	// Prefix of the discovery topics, as configured in Home Assistant.
+	DiscoveryPrefix string `yaml:"discovery_prefix,omitempty"`
+}
+
+type MQTTConfig struct {
+	// URL of the broker, e.g. tcp://localhost:1883 or ssl://broker:8883.
+	Broker        string               `yaml:"broker,omitempty"`
+	ClientID      string               `yaml:"client_id,omitempty"`
+	Username      string               `yaml:"username,omitempty"`
+	Password      string               `yaml:"password,omitempty"`
+	PasswordFile  string               `yaml:"password_file,omitempty"`
+	TopicPrefix   string               `yaml:"topic_prefix,omitempty"`
+	QoS           byte                 `yaml:"qos,omitempty"`
+	Retain        *bool                `yaml:"retain,omitempty"`
+	Timeout       time.Duration        `yaml:"timeout,omitempty"`
+	HomeAssistant *HomeAssistantConfig `yaml:"home_assistant,omitempty"`
+}
+
+func (c *MQTTConfig) Validate() error {
+	var errs []error
+	if len(c.Broker) == 0 {
+		errs = append(errs, errors.New("invalid mqtt config, no broker provided"))
+	} else if u, err := url.Parse(c.Broker); err != nil || u.Host == "" {
+		errs = append(errs, fmt.Errorf("invalid mqtt config, bad broker url: %s", c.Broker))
+	}
+
+	if len(c.Password) != 0 && len(c.PasswordFile) != 0 {
+		errs = append(errs, errors.New("invalid mqtt config, only one of password and password_file may be set"))
+	}
+	if c.QoS > 2 {
+		errs = append(errs, fmt.Errorf("invalid mqtt config, qos must be 0, 1 or 2: %d", c.QoS))
+	}
+
+	if len(c.ClientID) == 0 {
+		c.ClientID = defaultMQTTClientID
+	}
+	c.TopicPrefix = strings.Trim(c.TopicPrefix, "/")
+	if len(c.TopicPrefix) == 0 {
+		c.TopicPrefix = defaultMQTTTopicPrefix
+	}
+	if strings.ContainsAny(c.TopicPrefix, "+#") {
+		errs = append(errs, fmt.Errorf("invalid mqtt config, topic_prefix must not contain wildcards: %s", c.TopicPrefix))
+	}
+	if c.Retain == nil {
+		retain := true
+		c.Retain = &retain
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultMQTTTimeout
+	}
+	if c.HomeAssistant != nil && len(c.HomeAssistant.DiscoveryPrefix) == 0 {
+		c.HomeAssistant.DiscoveryPrefix = defaultHomeAssistantPrefix
+	}
+
+	return errors.Join(errs...)
+}
+
+// MQTTSink publishes the current value of every variable of each location
+// to <topic_prefix>/<location>/<variable>, and optionally the Home Assistant
+// discovery configuration of a sensor per variable.
+type MQTTSink struct {
+	Config *MQTTConfig
+	Client mqtt.Client
+
+	// Discovery messages already published since the last connect, keyed
+	// by topic with the unit of the sensor as value.
+	mu         sync.Mutex
+	discovered map[string]string
+}
+
+func NewMQTTSink(cfg *MQTTConfig) (*MQTTSink, error) {
+	s := &MQTTSink{Config: cfg, discovered: make(map[string]string)}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetConnectTimeout(cfg.Timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(mqtt.Client) {
+			level.Info(logger).Log("msg", "Connected to MQTT broker", "broker", cfg.Broker)
+			// Republish the discovery messages in case the broker lost them.
+			s.mu.Lock()
+			clear(s.discovered)
+			s.mu.Unlock()
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			level.Warn(logger).Log("msg", "Lost connection to MQTT broker", "broker", cfg.Broker, "err", err)
+		})
+
+	password := cfg.Password
+	if cfg.PasswordFile != "" {
+		data, err := os.ReadFile(cfg.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		password = strings.TrimSpace(string(data))
+	}
+	opts.SetPassword(password)
+
+	// With connect retry enabled, Connect keeps trying in the background
+	// after the first attempt.
+	s.Client = mqtt.NewClient(opts)
+	if !s.Client.Connect().WaitTimeout(cfg.Timeout) {
+		level.Warn(logger).Log("msg", "Not connected to MQTT broker yet, retrying in the background", "broker", cfg.Broker)
+	}
+	return s, nil
+}
+
+func (s *MQTTSink) Name() string {
+	return "mqtt"
+}
+
+func (s *MQTTSink) Write(ctx context.Context, data []*LocationData) error {
+	if !s.Client.IsConnectionOpen() {
+		return fmt.Errorf("not connected to mqtt broker %s", s.Config.Broker)
+	}
+
+	var errs []error
+	for _, d := range data {
+		for _, sample := range d.Samples() {
+			topic := s.stateTopic(&sample)
+
+			if s.Config.HomeAssistant != nil {
+				if err := s.publishDiscovery(ctx, d.Location, &sample, topic); err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			payload := strconv.FormatFloat(sample.Value, 'f', -1, 64)
+			if err := s.publish(ctx, topic, *s.Config.Retain, []byte(payload)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *MQTTSink) publish(ctx context.Context, topic string, retain bool, payload []byte) error {
+	token := s.Client.Publish(topic, s.Config.QoS, retain, payload)
+	select {
+	case <-token.Done():
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(s.Config.Timeout):
+		return fmt.Errorf("timed out publishing to %s", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Replaces the characters that cannot be used in a topic level.
+var mqttTopicEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+func (s *MQTTSink) stateTopic(sample *Sample) string {
+	return s.Config.TopicPrefix + "/" + mqttTopicEscaper.Replace(sample.Location) + "/" + sample.Variable
+}
+
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+// The discovery configuration of a sensor, see
+// https://www.home-assistant.io/integrations/sensor.mqtt/.
+type haSensorConfig struct {
+	Name              string    `json:"name"`
+	UniqueID          string    `json:"unique_id"`
+	ObjectID          string    `json:"object_id"`
+	StateTopic        string    `json:"state_topic"`
+	UnitOfMeasurement string    `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string    `json:"device_class,omitempty"`
+	StateClass        string    `json:"state_class,omitempty"`
+	Device            *haDevice `json:"device"`
+}
+
+// Publishes the discovery configuration of the sensor of a sample, unless
+// it was already published with the same unit.
+func (s *MQTTSink) publishDiscovery(ctx context.Context, loc *LocationConfig, sample *Sample, stateTopic string) error {
+	nodeID := haObjectID(loc.Name)
+	objectID := nodeID + "_" + sample.Variable
+	topic := s.Config.HomeAssistant.DiscoveryPrefix + "/sensor/" + nodeID + "/" + sample.Variable + "/config"
+
+	s.mu.Lock()
+	unit, ok := s.discovered[topic]
+	s.mu.Unlock()
+	if ok && unit == sample.Unit {
+		return nil
+	}
+
+	config := haSensorConfig{
+		Name:              variableTitle(sample.Variable),
+		UniqueID:          "openmeteo_" + objectID,
+		ObjectID:          "openmeteo_" + objectID,
+		StateTopic:        stateTopic,
+		UnitOfMeasurement: haUnit(sample.Unit),
+		DeviceClass:       haDeviceClass(sample.API, sample.Variable, sample.Unit),
+		StateClass:        "measurement",
+		Device: &haDevice{
+			Identifiers:  []string{"openmeteo_" + nodeID},
+			Name:         "Open-Meteo " + loc.Name,
+			Manufacturer: "Open-Meteo",
+			Model:        "openmeteo_exporter",
+			SWVersion:    version.Version,
+		},
+	}
+	if config.DeviceClass == "aqi" {
+		// The index is unitless in Home Assistant.
+		config.UnitOfMeasurement = ""
+	}
+	if v, err := LookupVariable(sample.API, sample.Variable); err == nil && !v.Averageable() {
+		config.StateClass = ""
+	}
+
+	payload, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	// Discovery messages are always retained so that Home Assistant finds
+	// the sensors after a restart.
+	if err = s.publish(ctx, topic, true, payload); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.discovered[topic] = sample.Unit
+	s.mu.Unlock()
+	return nil
+}
+
+// Home Assistant object ids may only contain [a-zA-Z0-9_-].
+func haObjectID(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// Units used by the API which are spelled differently in Home Assistant.
+var haUnits = map[string]string{
+	"mp/h":     "mph",
+	"inch":     "in",
+	"μg/m³":    "µg/m³",
+	"wmo code": "",
+}
+
+func haUnit(unit string) string {
+	if u, ok := haUnits[unit]; ok {
+		return u
+	}
+	return unit
+}
+
+// The units Home Assistant accepts for the device classes of the catalogue,
+// spelled as returned by haUnit.
+var haDeviceClassUnits = map[string][]string{
+	"temperature":          {"°C", "°F"},
+	"humidity":             {"%"},
+	"atmospheric_pressure": {"hPa", "kPa", "mbar", "inHg", "mmHg", "psi"},
+	"pressure":             {"hPa", "kPa", "mbar", "bar", "inHg", "mmHg", "psi", "Pa"},
+	"wind_speed":           {"km/h", "mph", "m/s", "kn", "ft/s"},
+	"wind_direction":       {"°"},
+	"precipitation":        {"mm", "cm", "in"},
+	"distance":             {"m", "km", "cm", "mm", "in", "ft", "yd", "mi"},
+	"irradiance":           {"W/m²", "BTU/(h⋅ft²)"},
+	"duration":             {"s", "min", "h", "d"},
+	"pm25":                 {"µg/m³"},
+	"pm10":                 {"µg/m³"},
+	"nitrogen_dioxide":     {"µg/m³"},
+	"sulphur_dioxide":      {"µg/m³"},
+	"ozone":                {"µg/m³"},
+	// The index is unitless in Home Assistant, the unit is left out.
+	"aqi": nil,
+}
+
+// Returns the Home Assistant device class of a variable of the catalogue,
+// provided Home Assistant accepts the unit it is exported in. Variables
+// without a device class, e.g. those that are not in the catalogue, have
+// none.
+func haDeviceClass(api, variable, unit string) string {
+	v, err := LookupVariable(api, variable)
+	if err != nil || v.DeviceClass == "" {
+		return ""
+	}
+	units, ok := haDeviceClassUnits[v.DeviceClass]
+	if !ok || (units != nil && !slices.Contains(units, haUnit(unit))) {
+		return ""
+	}
+	return v.DeviceClass
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// A token of a completed operation.
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Error() error                   { return nil }
+func (doneToken) Done() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+type mqttMessage struct {
+	retained bool
+	payload  string
+}
+
+// A connected MQTT client that records the published messages by topic.
+type fakeMQTTClient struct {
+	mqtt.Client
+
+	mu        sync.Mutex
+	published map[string][]mqttMessage
+}
+
+func (c *fakeMQTTClient) IsConnectionOpen() bool {
+	return true
+}
+
+func (c *fakeMQTTClient) Publish(topic string, qos byte, retained bool, payload any) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published[topic] = append(c.published[topic], mqttMessage{retained: retained, payload: string(payload.([]byte))})
+	return doneToken{}
+}
+
+func newTestMQTTSink(t *testing.T, cfg *MQTTConfig) (*MQTTSink, *fakeMQTTClient) {
+	t.Helper()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	client := &fakeMQTTClient{published: make(map[string][]mqttMessage)}
+	return &MQTTSink{Config: cfg, Client: client, discovered: make(map[string]string)}, client
+}
+
+func TestMQTTSinkStateTopics(t *testing.T) {
+	fake, _ := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice/Côte d'Azur", []string{"temperature_2m", "weather_code"}, []string{"pm2_5"}))
+	collector := OpenMeteoCollector{Client: fake, Locations: []LocationConfig{*loc}}
+
+	sink, client := newTestMQTTSink(t, &MQTTConfig{Broker: "tcp://localhost:1883", TopicPrefix: "/weather/"})
+	if err := sink.Write(context.Background(), collector.Fetch(context.Background())); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	tests := []struct {
+		topic, payload string
+	}{
+		{"weather/Nice_Côte d'Azur/temperature_2m", "65.12"},
+		{"weather/Nice_Côte d'Azur/pm2_5", "8.3"},
+	}
+	for _, tt := range tests {
+		msgs := client.published[tt.topic]
+		if len(msgs) != 1 || msgs[0].payload != tt.payload || !msgs[0].retained {
+			t.Errorf("unexpected messages on %s: %+v", tt.topic, msgs)
+		}
+	}
+	if _, ok := client.published["weather/Nice_Côte d'Azur/weather_code"]; !ok {
+		t.Error("weather_code was not published")
+	}
+	if n := len(client.published); n != 3 {
+		t.Errorf("expected 3 topics without discovery, got %d: %v", n, client.published)
+	}
+}
+
+func TestMQTTSinkDiscovery(t *testing.T) {
+	fake, _ := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m", "wind_direction_10m", "weather_code", "sunshine_duration"}, []string{"pm2_5", "us_aqi", "uv_index"}))
+	collector := OpenMeteoCollector{Client: fake, Locations: []LocationConfig{*loc}}
+
+	sink, client := newTestMQTTSink(t, &MQTTConfig{Broker: "tcp://localhost:1883", HomeAssistant: &HomeAssistantConfig{}})
+	data := collector.Fetch(context.Background())
+	for i := 0; i < 2; i++ {
+		if err := sink.Write(context.Background(), data); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+	}
+
+	tests := []struct {
+		variable, unit, deviceClass, stateClass string
+	}{
+		{"temperature_2m", "°F", "temperature", "measurement"},
+		{"wind_direction_10m", "°", "wind_direction", ""},
+		{"weather_code", "", "", ""},
+		{"sunshine_duration", "s", "duration", "measurement"},
+		{"pm2_5", "µg/m³", "pm25", "measurement"},
+		{"us_aqi", "", "aqi", "measurement"},
+		{"uv_index", "", "", "measurement"},
+	}
+	for _, tt := range tests {
+		topic := "homeassistant/sensor/nice/" + tt.variable + "/config"
+		msgs := client.published[topic]
+		// Only published again when the unit changes.
+		if len(msgs) != 1 || !msgs[0].retained {
+			t.Errorf("expected a single retained discovery message on %s, got %+v", topic, msgs)
+			continue
+		}
+
+		var config haSensorConfig
+		if err := json.Unmarshal([]byte(msgs[0].payload), &config); err != nil {
+			t.Fatalf("invalid discovery payload on %s: %v", topic, err)
+		}
+		if config.StateTopic != "openmeteo/Nice/"+tt.variable || config.UniqueID != "openmeteo_nice_"+tt.variable {
+			t.Errorf("unexpected topic or id for %s: %+v", tt.variable, config)
+		}
+		if config.UnitOfMeasurement != tt.unit || config.DeviceClass != tt.deviceClass || config.StateClass != tt.stateClass {
+			t.Errorf("%s: got unit %q, device class %q, state class %q, want %q, %q, %q",
+				tt.variable, config.UnitOfMeasurement, config.DeviceClass, config.StateClass, tt.unit, tt.deviceClass, tt.stateClass)
+		}
+		if config.Device == nil || config.Device.Name != "Open-Meteo Nice" {
+			t.Errorf("unexpected device for %s: %+v", tt.variable, config.Device)
+		}
+	}
+}
+
+func TestHADeviceClass(t *testing.T) {
+	tests := []struct {
+		api, variable, unit, want string
+	}{
+		{"weather", "temperature_2m", "°C", "temperature"},
+		{"weather", "soil_temperature_0cm", "°F", "temperature"},
+		{"weather", "wind_gusts_10m", "mp/h", "wind_speed"},
+		{"weather", "snowfall", "inch", "precipitation"},
+		{"weather", "direct_normal_irradiance", "W/m²", "irradiance"},
+		{"airquality", "ozone", "μg/m³", "ozone"},
+		{"airquality", "european_aqi_pm10", "EAQI", "aqi"},
+		// Home Assistant only accepts percentages for soil moisture.
+		{"weather", "soil_moisture_0_to_1cm", "m³/m³", ""},
+		// A unit Home Assistant doesn't accept for the class.
+		{"weather", "temperature_2m", "K", ""},
+		// Not in the catalogue.
+		{"weather", "wind_gusts_80m", "km/h", ""},
+	}
+	for _, tt := range tests {
+		if got := haDeviceClass(tt.api, tt.variable, tt.unit); got != tt.want {
+			t.Errorf("haDeviceClass(%q, %q, %q) = %q, want %q", tt.api, tt.variable, tt.unit, got, tt.want)
+		}
+	}
+}
+
+// Every device class of the catalogue must be known, so that a typo in
+// variables.yml doesn't silently drop it.
+func TestHADeviceClassesKnown(t *testing.T) {
+	for _, api := range []string{"weather", "airquality"} {
+		for _, v := range variableRegistry(api).Variables() {
+			if _, ok := haDeviceClassUnits[v.DeviceClass]; v.DeviceClass != "" && !ok {
+				t.Errorf("unknown device class %s of %s variable %s", v.DeviceClass, api, v.Name)
+			}
+		}
+	}
+}
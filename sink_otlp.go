@@ -0,0 +1,381 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/version"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	otlpProtocolHTTP = "http/protobuf"
+	otlpProtocolGRPC = "grpc"
+
+	defaultOTLPHTTPEndpoint = "http://localhost:4318/v1/metrics"
+	defaultOTLPGRPCEndpoint = "localhost:4317"
+	defaultOTLPMaxRetries   = 3
+	defaultOTLPTimeout      = 10 * time.Second
+
+	otlpScopeName = "github.com/thelande/openmeteo_exporter"
+)
+
+type OTLPConfig struct {
+	// Either http/protobuf (default) or grpc.
+	Protocol string `yaml:"protocol,omitempty"`
+	// A URL for http/protobuf, host:port for grpc.
+	Endpoint   string            `yaml:"endpoint,omitempty"`
+	Insecure   bool              `yaml:"insecure,omitempty"`
+	Headers    map[string]string `yaml:"headers,omitempty"`
+	MaxRetries *int              `yaml:"max_retries,omitempty"`
+	Timeout    time.Duration     `yaml:"timeout,omitempty"`
+}
+
+func (c *OTLPConfig) Validate() error {
+	var errs []error
+	switch c.Protocol {
+	case "":
+		c.Protocol = otlpProtocolHTTP
+	case otlpProtocolHTTP, otlpProtocolGRPC:
+	default:
+		errs = append(errs, fmt.Errorf("invalid otlp config, protocol must be %s or %s: %s", otlpProtocolHTTP, otlpProtocolGRPC, c.Protocol))
+	}
+
+	if len(c.Endpoint) == 0 {
+		if c.Protocol == otlpProtocolGRPC {
+			c.Endpoint = defaultOTLPGRPCEndpoint
+		} else {
+			c.Endpoint = defaultOTLPHTTPEndpoint
+		}
+	} else if c.Protocol == otlpProtocolHTTP {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid otlp config, bad endpoint url: %s", c.Endpoint))
+		} else if u.Path == "" || u.Path == "/" {
+			// Like the OpenTelemetry SDKs, default to the standard path.
+			u.Path = "/v1/metrics"
+			c.Endpoint = u.String()
+		}
+	}
+
+	if c.MaxRetries == nil {
+		retries := defaultOTLPMaxRetries
+		c.MaxRetries = &retries
+	} else if *c.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("invalid otlp config, max_retries must not be negative: %d", *c.MaxRetries))
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultOTLPTimeout
+	}
+
+	return errors.Join(errs...)
+}
+
+// Sends an export request, returning whether it may be retried along with
+// the error.
+type otlpExporter interface {
+	export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (bool, error)
+}
+
+// OTLPSink exports the current value of every variable as an OpenTelemetry
+// gauge, with a resource per location.
+type OTLPSink struct {
+	Config   *OTLPConfig
+	exporter otlpExporter
+}
+
+func NewOTLPSink(cfg *OTLPConfig) (*OTLPSink, error) {
+	s := &OTLPSink{Config: cfg}
+	if cfg.Protocol == otlpProtocolGRPC {
+		exporter, err := newOTLPGRPCExporter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s.exporter = exporter
+	} else {
+		s.exporter = &otlpHTTPExporter{Config: cfg, Client: &http.Client{Timeout: cfg.Timeout}}
+	}
+	return s, nil
+}
+
+func (s *OTLPSink) Name() string {
+	return "otlp"
+}
+
+func (s *OTLPSink) Write(ctx context.Context, data []*LocationData) error {
+	req := &colmetricspb.ExportMetricsServiceRequest{}
+	for _, d := range data {
+		if rm := otlpResourceMetrics(d); rm != nil {
+			req.ResourceMetrics = append(req.ResourceMetrics, rm)
+		}
+	}
+	if len(req.ResourceMetrics) == 0 {
+		return nil
+	}
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		retry, err := s.exporter.export(ctx, req)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= *s.Config.MaxRetries {
+			return err
+		}
+
+		level.Warn(logger).Log("msg", "Failed to export OTLP metrics, retrying", "attempt", attempt+1, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func otlpStringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func otlpDoubleAttr(key string, value float64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: value}}}
+}
+
+// Describes a location as a resource, using the semantic conventions for
+// geographic attributes where there is one.
+func otlpResource(d *LocationData) *resourcepb.Resource {
+	loc := d.Location
+	attrs := []*commonpb.KeyValue{
+		otlpStringAttr("service.name", "openmeteo_exporter"),
+		otlpStringAttr("service.version", version.Version),
+		otlpStringAttr("openmeteo.location.name", loc.Name),
+		otlpDoubleAttr("geo.location.lat", *loc.Latitude),
+		otlpDoubleAttr("geo.location.lon", *loc.Longitude),
+	}
+
+	// Prefer the timezone resolved by the API, the configuration may say
+	// auto.
+	timezone := loc.Timezone
+	if base := d.Base(); base != nil && base.Timezone != "" {
+		timezone = base.Timezone
+	}
+	attrs = append(attrs, otlpStringAttr("openmeteo.location.timezone", timezone))
+
+	if loc.Elevation != nil {
+		attrs = append(attrs, otlpDoubleAttr("openmeteo.location.elevation", *loc.Elevation))
+	} else if d.Weather != nil {
+		attrs = append(attrs, otlpDoubleAttr("openmeteo.location.elevation", d.Weather.Elevation))
+	}
+	if loc.Geocoded != nil && loc.Geocoded.CountryCode != "" {
+		attrs = append(attrs, otlpStringAttr("geo.country.iso_code", loc.Geocoded.CountryCode))
+	}
+
+	return &resourcepb.Resource{Attributes: attrs}
+}
+
+// Builds the metrics of a location, nil if no variable has a value.
+func otlpResourceMetrics(d *LocationData) *metricspb.ResourceMetrics {
+	samples := d.Samples()
+	if len(samples) == 0 {
+		return nil
+	}
+
+	var metrics []*metricspb.Metric
+	for _, sample := range samples {
+		ts := sample.Time
+		if ts.IsZero() {
+			ts = d.FetchedAt
+		}
+		desc := variableDescription(sample.API, sample.Variable)
+
+		metrics = append(metrics, &metricspb.Metric{
+			Name:        "openmeteo." + sample.API + "." + sample.Variable,
+			Description: desc,
+			Unit:        ucumUnit(sample.Unit),
+			Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+				DataPoints: []*metricspb.NumberDataPoint{{
+					TimeUnixNano: uint64(ts.UnixNano()),
+					Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: sample.Value},
+				}},
+			}},
+		})
+	}
+
+	return &metricspb.ResourceMetrics{
+		Resource: otlpResource(d),
+		ScopeMetrics: []*metricspb.ScopeMetrics{{
+			Scope:   &commonpb.InstrumentationScope{Name: otlpScopeName, Version: version.Version},
+			Metrics: metrics,
+		}},
+	}
+}
+
+// Mapping of the units returned by the APIs to UCUM, as OpenTelemetry
+// expects.
+var ucumUnits = map[string]string{
+	"°C":        "Cel",
+	"°F":        "[degF]",
+	"°":         "deg",
+	"%":         "%",
+	"km/h":      "km/h",
+	"mp/h":      "[mi_i]/h",
+	"m/s":       "m/s",
+	"kn":        "[kn_i]",
+	"mm":        "mm",
+	"cm":        "cm",
+	"m":         "m",
+	"inch":      "[in_i]",
+	"ft":        "[ft_i]",
+	"hPa":       "hPa",
+	"kPa":       "kPa",
+	"W/m²":      "W/m2",
+	"J/kg":      "J/kg",
+	"m³/m³":     "m3/m3",
+	"μg/m³":     "ug/m3",
+	"µg/m³":     "ug/m3",
+	"grains/m³": "{grains}/m3",
+	"wmo code":  "{code}",
+	"EAQI":      "{EAQI}",
+	"USAQI":     "{USAQI}",
+	"":          "1",
+}
+
+func ucumUnit(unit string) string {
+	if u, ok := ucumUnits[unit]; ok {
+		return u
+	}
+	// Unknown units are passed through as an annotation rather than
+	// guessed.
+	return "{" + unit + "}"
+}
+
+type otlpHTTPExporter struct {
+	Config *OTLPConfig
+	Client *http.Client
+}
+
+func (e *otlpHTTPExporter) export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (bool, error) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("User-Agent", "openmeteo_exporter/"+version.Version)
+	for name, value := range e.Config.Headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	resp, err := e.Client.Do(httpReq)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode/100 == 2 {
+		var exportResp colmetricspb.ExportMetricsServiceResponse
+		if err = proto.Unmarshal(respBody, &exportResp); err == nil {
+			logPartialSuccess(exportResp.GetPartialSuccess())
+		}
+		return false, nil
+	}
+
+	err = fmt.Errorf("otlp endpoint returned %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, err
+	}
+	return false, err
+}
+
+type otlpGRPCExporter struct {
+	Config *OTLPConfig
+	Client colmetricspb.MetricsServiceClient
+}
+
+func newOTLPGRPCExporter(cfg *OTLPConfig) (*otlpGRPCExporter, error) {
+	creds := credentials.NewTLS(&tls.Config{})
+	if cfg.Insecure {
+		creds = insecure.NewCredentials()
+	}
+
+	// The connection is established lazily on the first export.
+	conn, err := grpc.NewClient(cfg.Endpoint,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUserAgent("openmeteo_exporter/"+version.Version),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &otlpGRPCExporter{Config: cfg, Client: colmetricspb.NewMetricsServiceClient(conn)}, nil
+}
+
+func (e *otlpGRPCExporter) export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Config.Timeout)
+	defer cancel()
+
+	for name, value := range e.Config.Headers {
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(name), value)
+	}
+
+	resp, err := e.Client.Export(ctx, req)
+	if err == nil {
+		logPartialSuccess(resp.GetPartialSuccess())
+		return false, nil
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true, err
+	}
+	return false, err
+}
+
+func logPartialSuccess(partial *colmetricspb.ExportMetricsPartialSuccess) {
+	if partial.GetRejectedDataPoints() > 0 || partial.GetErrorMessage() != "" {
+		level.Warn(logger).Log(
+			"msg", "OTLP endpoint rejected some data points",
+			"rejected", partial.GetRejectedDataPoints(),
+			"err", partial.GetErrorMessage(),
+		)
+	}
+}
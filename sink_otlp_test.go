@@ -0,0 +1,194 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOTLPConfigValidate(t *testing.T) {
+	tests := []struct {
+		config   OTLPConfig
+		endpoint string
+	}{
+		{OTLPConfig{}, defaultOTLPHTTPEndpoint},
+		{OTLPConfig{Protocol: otlpProtocolGRPC}, defaultOTLPGRPCEndpoint},
+		{OTLPConfig{Endpoint: "https://otel.example.com"}, "https://otel.example.com/v1/metrics"},
+		{OTLPConfig{Endpoint: "https://otel.example.com/otlp/v1/metrics"}, "https://otel.example.com/otlp/v1/metrics"},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		} else if tt.config.Endpoint != tt.endpoint {
+			t.Errorf("expected endpoint %s, got %s", tt.endpoint, tt.config.Endpoint)
+		}
+	}
+
+	invalid := OTLPConfig{Protocol: "http/json"}
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected an error for an unsupported protocol")
+	}
+}
+
+func otlpTestData(t *testing.T) []*LocationData {
+	t.Helper()
+	client, _ := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, []string{"european_aqi"}))
+	collector := OpenMeteoCollector{Client: client, Locations: []LocationConfig{*loc}}
+	return collector.Fetch(context.Background())
+}
+
+// Returns the metrics of the only resource of a request by name, and the
+// attributes of the resource.
+func otlpTestMetrics(t *testing.T, req *colmetricspb.ExportMetricsServiceRequest) (map[string]*metricspb.Metric, map[string]any) {
+	t.Helper()
+	if len(req.GetResourceMetrics()) != 1 {
+		t.Fatalf("expected the metrics of one location, got %d", len(req.GetResourceMetrics()))
+	}
+	rm := req.GetResourceMetrics()[0]
+
+	attrs := make(map[string]any)
+	for _, kv := range rm.GetResource().GetAttributes() {
+		switch v := kv.GetValue().GetValue().(type) {
+		case *commonpb.AnyValue_StringValue:
+			attrs[kv.GetKey()] = v.StringValue
+		case *commonpb.AnyValue_DoubleValue:
+			attrs[kv.GetKey()] = v.DoubleValue
+		}
+	}
+
+	metrics := make(map[string]*metricspb.Metric)
+	for _, sm := range rm.GetScopeMetrics() {
+		for _, m := range sm.GetMetrics() {
+			metrics[m.GetName()] = m
+		}
+	}
+	return metrics, attrs
+}
+
+func checkOTLPRequest(t *testing.T, req *colmetricspb.ExportMetricsServiceRequest) {
+	t.Helper()
+	metrics, attrs := otlpTestMetrics(t, req)
+
+	if attrs["openmeteo.location.name"] != "Nice" || attrs["geo.location.lat"] != 43.7 {
+		t.Errorf("unexpected resource attributes %v", attrs)
+	}
+
+	temperature, ok := metrics["openmeteo.weather.temperature_2m"]
+	if !ok {
+		t.Fatalf("temperature metric not found in %v", metrics)
+	}
+	if temperature.GetUnit() != "[degF]" {
+		t.Errorf("expected unit [degF], got %s", temperature.GetUnit())
+	}
+	points := temperature.GetGauge().GetDataPoints()
+	if len(points) != 1 || points[0].GetAsDouble() != 65.12 || points[0].GetTimeUnixNano() == 0 {
+		t.Errorf("unexpected data points %v", points)
+	}
+
+	if aqi, ok := metrics["openmeteo.airquality.european_aqi"]; !ok || aqi.GetUnit() != "{EAQI}" {
+		t.Errorf("unexpected air quality metric %v", aqi)
+	}
+}
+
+func TestOTLPSinkHTTP(t *testing.T) {
+	requests := make(chan *colmetricspb.ExportMetricsServiceRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req colmetricspb.ExportMetricsServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests <- &req
+	}))
+	defer server.Close()
+
+	cfg := &OTLPConfig{Endpoint: server.URL, Headers: map[string]string{"X-Api-Key": "secret"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	sink, err := NewOTLPSink(cfg)
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	if err := sink.Write(context.Background(), otlpTestData(t)); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	checkOTLPRequest(t, <-requests)
+}
+
+// A metrics service receiving the requests of the gRPC exporter.
+type testMetricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	requests chan *colmetricspb.ExportMetricsServiceRequest
+	apiKeys  chan []string
+}
+
+func (s *testMetricsService) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.apiKeys <- md.Get("x-api-key")
+	s.requests <- req
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestOTLPSinkGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &testMetricsService{
+		requests: make(chan *colmetricspb.ExportMetricsServiceRequest, 1),
+		apiKeys:  make(chan []string, 1),
+	}
+	server := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(server, service)
+	go server.Serve(listener) //nolint:errcheck
+	defer server.Stop()
+
+	cfg := &OTLPConfig{Protocol: otlpProtocolGRPC, Endpoint: listener.Addr().String(), Insecure: true, Headers: map[string]string{"X-Api-Key": "secret"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	sink, err := NewOTLPSink(cfg)
+	if err != nil {
+		t.Fatalf("failed to create sink: %v", err)
+	}
+	if err := sink.Write(context.Background(), otlpTestData(t)); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	if keys := <-service.apiKeys; len(keys) != 1 || keys[0] != "secret" {
+		t.Errorf("headers were not sent as metadata: %v", keys)
+	}
+	checkOTLPRequest(t, <-service.requests)
+}
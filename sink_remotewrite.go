@@ -0,0 +1,400 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	defaultRemoteWriteQueueCapacity = 100
+	defaultRemoteWriteMinBackoff    = time.Second
+	defaultRemoteWriteMaxBackoff    = 30 * time.Second
+	defaultRemoteWriteTimeout       = 30 * time.Second
+)
+
+var (
+	remoteWriteSamplesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "remote_write_samples_total",
+			Help:      "The number of samples handled by the remote write output, by result.",
+		},
+		[]string{"result"},
+	)
+
+	remoteWriteQueueLength = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "remote_write_queue_length",
+			Help:      "The number of requests waiting to be sent by the remote write output.",
+		},
+	)
+)
+
+type BasicAuthConfig struct {
+	Username     string `yaml:"username,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+}
+
+type RemoteWriteConfig struct {
+	URL             string            `yaml:"url,omitempty"`
+	BasicAuth       *BasicAuthConfig  `yaml:"basic_auth,omitempty"`
+	BearerToken     string            `yaml:"bearer_token,omitempty"`
+	BearerTokenFile string            `yaml:"bearer_token_file,omitempty"`
+	ExternalLabels  map[string]string `yaml:"external_labels,omitempty"`
+	QueueCapacity   int               `yaml:"queue_capacity,omitempty"`
+	MinBackoff      time.Duration     `yaml:"min_backoff,omitempty"`
+	MaxBackoff      time.Duration     `yaml:"max_backoff,omitempty"`
+	Timeout         time.Duration     `yaml:"timeout,omitempty"`
+}
+
+func (c *RemoteWriteConfig) Validate() error {
+	var errs []error
+	if len(c.URL) == 0 {
+		errs = append(errs, errors.New("invalid remote_write config, no url provided"))
+	} else if u, err := url.Parse(c.URL); err != nil || u.Host == "" {
+		errs = append(errs, fmt.Errorf("invalid remote_write config, bad url: %s", c.URL))
+	}
+
+	auths := 0
+	if c.BasicAuth != nil {
+		auths++
+		if len(c.BasicAuth.Username) == 0 {
+			errs = append(errs, errors.New("invalid remote_write config, basic_auth requires a username"))
+		}
+		if len(c.BasicAuth.Password) != 0 && len(c.BasicAuth.PasswordFile) != 0 {
+			errs = append(errs, errors.New("invalid remote_write config, only one of password and password_file may be set"))
+		}
+	}
+	if len(c.BearerToken) != 0 {
+		auths++
+	}
+	if len(c.BearerTokenFile) != 0 {
+		auths++
+	}
+	if auths > 1 {
+		errs = append(errs, errors.New("invalid remote_write config, only one of basic_auth, bearer_token and bearer_token_file may be set"))
+	}
+
+	if c.QueueCapacity <= 0 {
+		c.QueueCapacity = defaultRemoteWriteQueueCapacity
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = defaultRemoteWriteMinBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultRemoteWriteMaxBackoff
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		errs = append(errs, errors.New("invalid remote_write config, max_backoff must not be less than min_backoff"))
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultRemoteWriteTimeout
+	}
+
+	return errors.Join(errs...)
+}
+
+// A compressed request waiting to be sent.
+type remoteWriteRequest struct {
+	body    []byte
+	samples int
+}
+
+// RemoteWriteSink sends the metrics produced by the collectors for every poll
+// using the Prometheus remote write protocol. Requests are kept in a bounded
+// in-memory queue and retried until they succeed, dropping the oldest
+// requests when the queue is full.
+type RemoteWriteSink struct {
+	Config    *RemoteWriteConfig
+	Collector OpenMeteoCollector
+	Client    *http.Client
+
+	mu      sync.Mutex
+	queue   []remoteWriteRequest
+	pending chan struct{}
+}
+
+func NewRemoteWriteSink(ctx context.Context, cfg *RemoteWriteConfig, collector OpenMeteoCollector) *RemoteWriteSink {
+	s := &RemoteWriteSink{
+		Config:    cfg,
+		Collector: collector,
+		Client:    &http.Client{Timeout: cfg.Timeout},
+		pending:   make(chan struct{}, 1),
+	}
+	go s.run(ctx)
+	return s
+}
+
+func (s *RemoteWriteSink) Name() string {
+	return "remote_write"
+}
+
+// Write encodes the metrics of the polled data and queues them for sending.
+func (s *RemoteWriteSink) Write(ctx context.Context, data []*LocationData) error {
+	families, err := gatherLocationData(s.Collector, data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	body, samples := encodeWriteRequest(families, s.Config.ExternalLabels, now)
+	if samples == 0 {
+		return nil
+	}
+
+	s.enqueue(remoteWriteRequest{body: s2.EncodeSnappy(nil, body), samples: samples})
+	return nil
+}
+
+func (s *RemoteWriteSink) enqueue(req remoteWriteRequest) {
+	s.mu.Lock()
+	if len(s.queue) >= s.Config.QueueCapacity {
+		s.drop(s.queue[0])
+		s.queue = s.queue[1:]
+	}
+	s.queue = append(s.queue, req)
+	remoteWriteQueueLength.Set(float64(len(s.queue)))
+	s.mu.Unlock()
+
+	select {
+	case s.pending <- struct{}{}:
+	default:
+	}
+}
+
+func (s *RemoteWriteSink) drop(req remoteWriteRequest) {
+	remoteWriteSamplesTotal.WithLabelValues("dropped").Add(float64(req.samples))
+	level.Warn(logger).Log("msg", "Remote write queue is full, dropping oldest request", "samples", req.samples)
+}
+
+// Removes the request at the head of the queue to send it. The request being
+// sent is out of the queue, so that enqueue can't drop it meanwhile and each
+// request ends up counted exactly once.
+func (s *RemoteWriteSink) take() (remoteWriteRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return remoteWriteRequest{}, false
+	}
+	req := s.queue[0]
+	s.queue = s.queue[1:]
+	remoteWriteQueueLength.Set(float64(len(s.queue)))
+	return req, true
+}
+
+// Puts a request that failed back at the head of the queue to retry it. As
+// the oldest request, it is dropped instead if the queue filled up while it
+// was being sent.
+func (s *RemoteWriteSink) requeue(req remoteWriteRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) >= s.Config.QueueCapacity {
+		s.drop(req)
+		return
+	}
+	s.queue = slices.Insert(s.queue, 0, req)
+	remoteWriteQueueLength.Set(float64(len(s.queue)))
+}
+
+func (s *RemoteWriteSink) run(ctx context.Context) {
+	backoff := s.Config.MinBackoff
+	for {
+		req, ok := s.take()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.pending:
+				continue
+			}
+		}
+
+		retry, err := s.send(ctx, req.body)
+		if err == nil {
+			remoteWriteSamplesTotal.WithLabelValues("sent").Add(float64(req.samples))
+			backoff = s.Config.MinBackoff
+			continue
+		}
+
+		if !retry {
+			remoteWriteSamplesTotal.WithLabelValues("failed").Add(float64(req.samples))
+			level.Error(logger).Log("msg", "Remote write rejected request, dropping it", "samples", req.samples, "err", err)
+			continue
+		}
+
+		s.requeue(req)
+		level.Warn(logger).Log("msg", "Failed to send remote write request, retrying", "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, s.Config.MaxBackoff)
+	}
+}
+
+// Returns whether the request may be retried along with the error.
+func (s *RemoteWriteSink) send(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "openmeteo_exporter/"+version.Version)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	if err = s.authorize(req); err != nil {
+		return false, err
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body) //nolint:errcheck
+		return false, nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("remote write endpoint returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+func (s *RemoteWriteSink) authorize(req *http.Request) error {
+	switch {
+	case s.Config.BasicAuth != nil:
+		password := s.Config.BasicAuth.Password
+		if s.Config.BasicAuth.PasswordFile != "" {
+			data, err := os.ReadFile(s.Config.BasicAuth.PasswordFile)
+			if err != nil {
+				return err
+			}
+			password = strings.TrimSpace(string(data))
+		}
+		req.SetBasicAuth(s.Config.BasicAuth.Username, password)
+	case s.Config.BearerTokenFile != "":
+		data, err := os.ReadFile(s.Config.BearerTokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(data)))
+	case s.Config.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+s.Config.BearerToken)
+	}
+	return nil
+}
+
+// Encodes the gauges of the metric families as a remote write WriteRequest
+// protobuf message and returns it with the number of samples.
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(families []*dto.MetricFamily, externalLabels map[string]string, now time.Time) ([]byte, int) {
+	var buf []byte
+	samples := 0
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			var value float64
+			switch {
+			case metric.Gauge != nil:
+				value = metric.GetGauge().GetValue()
+			case metric.Counter != nil:
+				value = metric.GetCounter().GetValue()
+			default:
+				continue
+			}
+
+			ts := now.UnixMilli()
+			if metric.TimestampMs != nil {
+				ts = metric.GetTimestampMs()
+			}
+
+			labels := map[string]string{"__name__": family.GetName()}
+			for name, value := range externalLabels {
+				labels[name] = value
+			}
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+
+			// Labels must be sorted by name.
+			names := make([]string, 0, len(labels))
+			for name := range labels {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+
+			var series []byte
+			for _, name := range names {
+				if labels[name] == "" {
+					continue
+				}
+				var label []byte
+				label = protowire.AppendTag(label, 1, protowire.BytesType)
+				label = protowire.AppendString(label, name)
+				label = protowire.AppendTag(label, 2, protowire.BytesType)
+				label = protowire.AppendString(label, labels[name])
+
+				series = protowire.AppendTag(series, 1, protowire.BytesType)
+				series = protowire.AppendBytes(series, label)
+			}
+
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(ts))
+
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, sample)
+
+			buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+			buf = protowire.AppendBytes(buf, series)
+			samples++
+		}
+	}
+
+	return buf, samples
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// A decoded time series of a remote write request.
+type testSeries struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// Decodes the fields of a protobuf message, failing the test on malformed
+// input.
+func consumeFields(t *testing.T, b []byte, fn func(num protowire.Number, typ protowire.Type, value []byte)) {
+	t.Helper()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("malformed tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		m := protowire.ConsumeFieldValue(num, typ, b)
+		if m < 0 {
+			t.Fatalf("malformed field %d: %v", num, protowire.ParseError(m))
+		}
+		fn(num, typ, b[:m])
+		b = b[m:]
+	}
+}
+
+func decodeWriteRequest(t *testing.T, b []byte) map[string]testSeries {
+	t.Helper()
+	series := make(map[string]testSeries)
+	consumeFields(t, b, func(num protowire.Number, _ protowire.Type, value []byte) {
+		if num != 1 {
+			t.Fatalf("unexpected field %d in WriteRequest", num)
+		}
+		ts, _ := protowire.ConsumeBytes(value)
+		s := testSeries{labels: make(map[string]string)}
+		consumeFields(t, ts, func(num protowire.Number, _ protowire.Type, value []byte) {
+			msg, _ := protowire.ConsumeBytes(value)
+			switch num {
+			case 1:
+				var name, val string
+				consumeFields(t, msg, func(num protowire.Number, _ protowire.Type, value []byte) {
+					s, _ := protowire.ConsumeString(value)
+					if num == 1 {
+						name = s
+					} else {
+						val = s
+					}
+				})
+				s.labels[name] = val
+			case 2:
+				consumeFields(t, msg, func(num protowire.Number, _ protowire.Type, value []byte) {
+					if num == 1 {
+						bits, _ := protowire.ConsumeFixed64(value)
+						s.value = math.Float64frombits(bits)
+					} else {
+						v, _ := protowire.ConsumeVarint(value)
+						s.timestamp = int64(v)
+					}
+				})
+			}
+		})
+		key := s.labels["__name__"] + "/" + s.labels["location"]
+		if source, ok := s.labels["source"]; ok {
+			key += "/" + source
+		}
+		series[key] = s
+	})
+	return series
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	client, _ := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+	collector := OpenMeteoCollector{Client: client, Locations: []LocationConfig{*loc}}
+	families, err := gatherLocationData(collector, collector.Fetch(context.Background()))
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	now := time.Unix(1717243200, 0)
+	body, samples := encodeWriteRequest(families, map[string]string{"site": "riviera"}, now)
+	series := decodeWriteRequest(t, body)
+	if samples != len(series) {
+		t.Errorf("reported %d samples, decoded %d series", samples, len(series))
+	}
+
+	s, ok := series["openmeteo_weather_temperature_2m_fahrenheit/Nice"]
+	if !ok {
+		t.Fatalf("temperature series not found in %v", series)
+	}
+	if s.value != 65.12 || s.timestamp != now.UnixMilli() {
+		t.Errorf("unexpected sample %v at %d", s.value, s.timestamp)
+	}
+	if s.labels["site"] != "riviera" {
+		t.Errorf("external label missing: %v", s.labels)
+	}
+}
+
+func TestRemoteWriteSink(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	received := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- request{header: r.Header, body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, _ := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, nil))
+	collector := OpenMeteoCollector{Client: client, Locations: []LocationConfig{*loc}}
+
+	cfg := &RemoteWriteConfig{URL: server.URL, BearerToken: "secret"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sink := NewRemoteWriteSink(ctx, cfg, collector)
+	if err := sink.Write(ctx, collector.Fetch(ctx)); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	var req request
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request was sent")
+	}
+	if req.header.Get("Content-Encoding") != "snappy" || req.header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("unexpected headers: %v", req.header)
+	}
+	body, err := s2.Decode(nil, req.body)
+	if err != nil {
+		t.Fatalf("body is not snappy compressed: %v", err)
+	}
+	if _, ok := decodeWriteRequest(t, body)["openmeteo_weather_temperature_2m_fahrenheit/Nice"]; !ok {
+		t.Error("temperature series was not sent")
+	}
+}
+
+func TestRemoteWriteSinkDropWhileSending(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := &RemoteWriteConfig{URL: server.URL, QueueCapacity: 1}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sent := remoteWriteSamplesTotal.WithLabelValues("sent")
+	dropped := remoteWriteSamplesTotal.WithLabelValues("dropped")
+	sentBefore, droppedBefore := testutil.ToFloat64(sent), testutil.ToFloat64(dropped)
+
+	sink := NewRemoteWriteSink(ctx, cfg, OpenMeteoCollector{})
+	sink.enqueue(remoteWriteRequest{body: []byte("a"), samples: 1})
+	<-started
+
+	// The request being sent must not be dropped to make room, the oldest
+	// waiting one is.
+	sink.enqueue(remoteWriteRequest{body: []byte("b"), samples: 2})
+	sink.enqueue(remoteWriteRequest{body: []byte("c"), samples: 4})
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(sent)-sentBefore < 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(sent) - sentBefore; got != 5 {
+		t.Errorf("expected 5 samples sent, got %v", got)
+	}
+	if got := testutil.ToFloat64(dropped) - droppedBefore; got != 2 {
+		t.Errorf("expected 2 samples dropped, got %v", got)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var apiErrorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_errors_total",
+		Help:      "The number of failed requests to the Open-Meteo APIs.",
+	},
+	[]string{"location", "api", "reason_class"},
+)
+
+// Groups an error returned by the client into a small set of classes that
+// are safe to use as a label value.
+func errorReasonClass(err error) string {
+	var apiErr *APIError
+	var decodeErr *DecodeError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		return "timeout"
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return "rate_limited"
+		case apiErr.StatusCode == http.StatusBadRequest:
+			return "bad_request"
+		case apiErr.StatusCode >= 500:
+			return "server_error"
+		case apiErr.StatusCode >= 400:
+			return "client_error"
+		default:
+			return "error_response"
+		}
+	case errors.As(err, &decodeErr):
+		return "decode"
+	default:
+		return "network"
+	}
+}
+
+// The outcome of the last requests to one of the APIs for a location.
+type apiStatus struct {
+	LastSuccess   time.Time
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// StatusTracker keeps the outcome of the last requests made for each
+// location so that it can be shown to operators.
+type StatusTracker struct {
+	mu       sync.Mutex
+	statuses map[string]map[string]*apiStatus
+}
+
+func NewStatusTracker() *StatusTracker {
+	return &StatusTracker{statuses: make(map[string]map[string]*apiStatus)}
+}
+
+func (t *StatusTracker) get(location, api string) *apiStatus {
+	apis, ok := t.statuses[location]
+	if !ok {
+		apis = make(map[string]*apiStatus)
+		t.statuses[location] = apis
+	}
+	status, ok := apis[api]
+	if !ok {
+		status = &apiStatus{}
+		apis[api] = status
+	}
+	return status
+}
+
+func (t *StatusTracker) RecordSuccess(location, api string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.get(location, api).LastSuccess = time.Now()
+}
+
+func (t *StatusTracker) RecordError(location, api string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := t.get(location, api)
+	status.LastError = err.Error()
+	status.LastErrorTime = time.Now()
+}
+
+// Get returns the outcome of the last requests to an API for a location.
+func (t *StatusTracker) Get(location, api string) (apiStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status, ok := t.statuses[location][api]
+	if !ok {
+		return apiStatus{}, false
+	}
+	return *status, true
+}
+
+// Records the outcome of a request to an API for a location: the error
+// counter, the status tracker (when set) and the log.
+func recordResult(status *StatusTracker, loc *LocationConfig, api string, err error) {
+	if err == nil {
+		if status != nil {
+			status.RecordSuccess(loc.Name, api)
+		}
+		return
+	}
+
+	apiErrorsTotal.WithLabelValues(loc.Name, api, errorReasonClass(err)).Inc()
+	if status != nil {
+		status.RecordError(loc.Name, api, err)
+	}
+	level.Warn(logger).Log(
+		"msg", "Failed to collect "+api+" information",
+		"location", loc.Name,
+		"err", err,
+	)
+}
+
+type statusRow struct {
+	Location string
+	API      string
+	apiStatus
+}
+
+// Returns true if the last request for the API failed.
+func (r statusRow) Failing() bool {
+	return r.LastErrorTime.After(r.LastSuccess)
+}
+
+func (t *StatusTracker) rows() []statusRow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var rows []statusRow
+	for location, apis := range t.statuses {
+		for api, status := range apis {
+			rows = append(rows, statusRow{Location: location, API: api, apiStatus: *status})
+		}
+	}
+	slices.SortFunc(rows, func(a, b statusRow) int {
+		return cmp.Or(strings.Compare(a.Location, b.Location), strings.Compare(a.API, b.API))
+	})
+	return rows
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Open-Meteo Exporter Status</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.failing { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Open-Meteo Exporter Status</h1>
+{{if .}}
+<table>
+<tr><th>Location</th><th>API</th><th>Last Success</th><th>Last Error</th><th>Error Time</th></tr>
+{{range .}}
+<tr{{if .Failing}} class="failing"{{end}}>
+<td>{{.Location}}</td>
+<td>{{.API}}</td>
+<td>{{if .LastSuccess.IsZero}}never{{else}}{{.LastSuccess.Format "2006-01-02T15:04:05Z07:00"}}{{end}}</td>
+<td>{{.LastError}}</td>
+<td>{{if not .LastErrorTime.IsZero}}{{.LastErrorTime.Format "2006-01-02T15:04:05Z07:00"}}{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No requests have been made yet.</p>
+{{end}}
+</body>
+</html>
+`))
+
+func (t *StatusTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, t.rows()); err != nil {
+		level.Error(logger).Log("msg", "Failed to render status page", "err", err)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestErrorReasonClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&APIError{StatusCode: http.StatusTooManyRequests}, "rate_limited"},
+		{&APIError{StatusCode: http.StatusBadRequest, Reason: "Invalid timezone"}, "bad_request"},
+		{&APIError{StatusCode: http.StatusNotFound}, "client_error"},
+		{&APIError{StatusCode: http.StatusBadGateway}, "server_error"},
+		{&APIError{StatusCode: http.StatusOK, Reason: "Cannot initialize"}, "error_response"},
+		{fmt.Errorf("weather: %w", &DecodeError{API: "weather", Err: errors.New("unexpected EOF")}), "decode"},
+		{fmt.Errorf("request: %w", context.DeadlineExceeded), "timeout"},
+		{errors.New("connection refused"), "network"},
+	}
+	for _, tt := range tests {
+		if got := errorReasonClass(tt.err); got != tt.want {
+			t.Errorf("errorReasonClass(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestStatusPage(t *testing.T) {
+	client, fake := newFakeClient(t)
+	loc := validLocation(t, testLocation("Nice", []string{"temperature_2m"}, []string{"pm2_5"}))
+	status := NewStatusTracker()
+
+	// Only one of the two requests fails, whichever is sent first.
+	fake.InjectError(http.StatusBadRequest, "Latitude must be in range of -90 to 90°.", 1)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(OpenMeteoCollector{Client: client, Locations: []LocationConfig{*loc}, Status: status})
+	if _, err := registry.Gather(); err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	status.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	page := rec.Body.String()
+
+	if n := strings.Count(page, "<td>Nice</td>"); n != 2 {
+		t.Errorf("expected a row per API of the location, got %d", n)
+	}
+	if n := strings.Count(page, `class="failing"`); n != 1 {
+		t.Errorf("expected one failing row, got %d", n)
+	}
+	if !strings.Contains(page, "Latitude must be in range of -90 to 90°.") {
+		t.Errorf("the reason of the error is not shown:\n%s", page)
+	}
+}
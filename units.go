@@ -0,0 +1,86 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Units returned by the API that need a specific name in metric names.
+var unitSuffixes = map[string]string{
+	"°F":        "fahrenheit",
+	"°C":        "celsius",
+	"°":         "degrees",
+	"%":         "percent",
+	"wmo code":  "",
+	"mp/h":      "mph",
+	"μg/m³":     "ug_per_m3",
+	"µg/m³":     "ug_per_m3",
+	"grains/m³": "grains_per_m3",
+	"Grains/m³": "grains_per_m3",
+}
+
+var unitReplacer = strings.NewReplacer(
+	"/", "_per_",
+	"²", "2",
+	"³", "3",
+	"μ", "u",
+	"µ", "u",
+	"°", "deg",
+	"%", "percent",
+)
+
+// Converts a unit returned by the API into something Prometheus accepts as
+// part of a metric name. Unknown units are sanitized rather than rejected so
+// that an unexpected unit can never produce an invalid metric name.
+func metricUnitSuffix(api, unit string) string {
+	suffix, ok := unitSuffixes[unit]
+	if !ok {
+		var b strings.Builder
+		for _, r := range unitReplacer.Replace(strings.TrimSpace(unit)) {
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				b.WriteRune(r)
+			} else {
+				b.WriteRune('_')
+			}
+		}
+		suffix = strings.Trim(b.String(), "_")
+		for strings.Contains(suffix, "__") {
+			suffix = strings.ReplaceAll(suffix, "__", "_")
+		}
+	}
+
+	// Air quality units have always been lower case.
+	if api == "airquality" {
+		suffix = strings.ToLower(suffix)
+	}
+	return suffix
+}
+
+// Returns the name of the metric exported for a variable with the unit
+// returned by the API.
+func variableMetricName(api, name, unit string) string {
+	// Omit the underscore separating the name and units if there are no units.
+	// Air quality metrics have always kept it, e.g. uv_index_, so existing
+	// queries keep working.
+	if suffix := metricUnitSuffix(api, unit); suffix != "" || api == "airquality" {
+		return prometheus.BuildFQName(namespace, api, fmt.Sprintf("%s_%s", name, suffix))
+	}
+	return prometheus.BuildFQName(namespace, api, name)
+}
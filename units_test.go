@@ -0,0 +1,67 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import "testing"
+
+func TestMetricUnitSuffix(t *testing.T) {
+	tests := []struct {
+		api, unit, want string
+	}{
+		{"weather", "°C", "celsius"},
+		{"weather", "°F", "fahrenheit"},
+		{"weather", "°", "degrees"},
+		{"weather", "%", "percent"},
+		{"weather", "wmo code", ""},
+		{"weather", "", ""},
+		{"weather", "km/h", "km_per_h"},
+		{"weather", "mp/h", "mph"},
+		{"weather", "W/m²", "W_per_m2"},
+		{"weather", "m³/m³", "m3_per_m3"},
+		{"weather", "J/kg", "J_per_kg"},
+		{"airquality", "μg/m³", "ug_per_m3"},
+		{"airquality", "EAQI", "eaqi"},
+		{"airquality", "Grains/m³", "grains_per_m3"},
+		// Unknown units never produce an invalid metric name.
+		{"weather", " a (b) ", "a_b"},
+		{"weather", "x--y", "x_y"},
+	}
+	for _, tt := range tests {
+		if got := metricUnitSuffix(tt.api, tt.unit); got != tt.want {
+			t.Errorf("metricUnitSuffix(%q, %q) = %q, want %q", tt.api, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestVariableMetricName(t *testing.T) {
+	tests := []struct {
+		api, name, unit, want string
+	}{
+		{"weather", "temperature_2m", "°C", "openmeteo_weather_temperature_2m_celsius"},
+		{"weather", "weather_code", "wmo code", "openmeteo_weather_weather_code"},
+		{"weather", "is_day", "", "openmeteo_weather_is_day"},
+		{"airquality", "pm10", "μg/m³", "openmeteo_airquality_pm10_ug_per_m3"},
+		{"airquality", "us_aqi", "USAQI", "openmeteo_airquality_us_aqi_usaqi"},
+		// Unitless air quality variables keep the trailing underscore.
+		{"airquality", "uv_index", "", "openmeteo_airquality_uv_index_"},
+		{"airquality", "aerosol_optical_depth", "", "openmeteo_airquality_aerosol_optical_depth_"},
+	}
+	for _, tt := range tests {
+		if got := variableMetricName(tt.api, tt.name, tt.unit); got != tt.want {
+			t.Errorf("variableMetricName(%q, %q, %q) = %q, want %q", tt.api, tt.name, tt.unit, got, tt.want)
+		}
+	}
+}
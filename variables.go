@@ -0,0 +1,274 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+//go:generate go run ./internal/genvariables -spec variables.yml -out variables_gen.go
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// UnitFamily is the unit setting of the Weather API that determines the
+// unit a variable is returned in.
+type UnitFamily string
+
+const (
+	// The unit does not depend on any setting.
+	UnitFamilyFixed         UnitFamily = "fixed"
+	UnitFamilyTemperature   UnitFamily = "temperature"
+	UnitFamilyWindSpeed     UnitFamily = "wind_speed"
+	UnitFamilyPrecipitation UnitFamily = "precipitation"
+)
+
+// ValueType describes what the value of a variable represents.
+type ValueType string
+
+const (
+	// A physical quantity that can be averaged and aggregated.
+	ValueTypeMeasurement ValueType = "measurement"
+	// A dimensionless index on a fixed scale, e.g. an AQI or the UV index.
+	ValueTypeIndex ValueType = "index"
+	// An angle in degrees, which cannot be averaged arithmetically.
+	ValueTypeDirection ValueType = "direction"
+	// A numeric code, e.g. a WMO weather code.
+	ValueTypeCode ValueType = "code"
+	// Either 0 or 1.
+	ValueTypeFlag ValueType = "flag"
+)
+
+// Region is where a variable is available.
+type Region string
+
+const (
+	RegionGlobal Region = "global"
+	RegionEurope Region = "Europe only"
+)
+
+// Block is one of the sections of a response a variable can be requested in.
+type Block string
+
+const (
+	BlockCurrent Block = "current"
+	BlockHourly  Block = "hourly"
+	BlockDaily   Block = "daily"
+)
+
+// Variable describes a variable that can be requested from one of the APIs.
+type Variable struct {
+	Name     string
+	Category string
+	// The unit returned with the default, metric, unit settings.
+	Unit       string
+	UnitFamily UnitFamily
+	ValueType  ValueType
+	Region     Region
+	Blocks     []Block
+	// The Home Assistant device class of the sensor of the variable, if
+	// any.
+	DeviceClass string
+
+	Description string
+}
+
+// Supports reports whether the variable can be requested in a block.
+func (v Variable) Supports(block Block) bool {
+	return slices.Contains(v.Blocks, block)
+}
+
+// Averageable reports whether values of the variable can be averaged, which
+// codes, flags and angles cannot.
+func (v Variable) Averageable() bool {
+	return v.ValueType == ValueTypeMeasurement || v.ValueType == ValueTypeIndex
+}
+
+// Returns the unit the variable is returned in with the unit settings of a
+// weather config. Air quality variables always use their default unit.
+func (v Variable) UnitFor(w *WeatherConfig) string {
+	if w == nil {
+		return v.Unit
+	}
+
+	switch v.UnitFamily {
+	case UnitFamilyTemperature:
+		if w.TemperatureUnit == "fahrenheit" {
+			return "°F"
+		}
+	case UnitFamilyWindSpeed:
+		switch w.WindSpeedUnit {
+		case "mph":
+			return "mp/h"
+		case "ms":
+			return "m/s"
+		case "kn":
+			return "kn"
+		}
+	case UnitFamilyPrecipitation:
+		if w.PrecipitationUnit == "inch" {
+			return "inch"
+		}
+	}
+	return v.Unit
+}
+
+// VariableRegistry holds the variables of one of the APIs. The registries
+// of both APIs are generated from variables.yml into variables_gen.go.
+type VariableRegistry struct {
+	API       string
+	variables map[string]Variable
+}
+
+func NewVariableRegistry(api string, variables []Variable) *VariableRegistry {
+	r := &VariableRegistry{API: api, variables: make(map[string]Variable, len(variables))}
+	for _, v := range variables {
+		if _, ok := r.variables[v.Name]; ok {
+			panic(fmt.Sprintf("duplicate %s variable: %s", api, v.Name))
+		}
+		r.variables[v.Name] = v
+	}
+	return r
+}
+
+// Lookup returns the variable with the given name.
+func (r *VariableRegistry) Lookup(name string) (Variable, bool) {
+	v, ok := r.variables[name]
+	return v, ok
+}
+
+// Variables returns all variables sorted by name.
+func (r *VariableRegistry) Variables() []Variable {
+	return slices.SortedFunc(maps.Values(r.variables), func(a, b Variable) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// Returns the registry of an API, nil if the API is unknown.
+func variableRegistry(api string) *VariableRegistry {
+	switch api {
+	case "weather":
+		return weatherVariables
+	case "airquality":
+		return airQualityVariables
+	}
+	return nil
+}
+
+// LookupVariable returns a variable of an API.
+func LookupVariable(api, name string) (Variable, error) {
+	registry := variableRegistry(api)
+	if registry == nil {
+		return Variable{}, fmt.Errorf("unknown api: %s", api)
+	}
+	v, ok := registry.Lookup(name)
+	if !ok {
+		return Variable{}, fmt.Errorf("invalid %s variable name: %s", api, name)
+	}
+	return v, nil
+}
+
+// Returns the description of a variable. Variables that are not in the
+// catalogue, which are only accepted with lenient_variables, get a generic
+// one.
+func variableDescription(api, name string) string {
+	v, err := LookupVariable(api, name)
+	if err != nil {
+		return fmt.Sprintf("The %s variable %s of the Open-Meteo API.", api, name)
+	}
+	return v.Description
+}
+
+// Words of variable names that are written in upper case.
+var titleAcronyms = map[string]string{
+	"aqi":  "AQI",
+	"cape": "CAPE",
+	"msl":  "MSL",
+	"pm10": "PM10",
+	"us":   "US",
+	"uv":   "UV",
+}
+
+// Builds a display name from a variable name, e.g. temperature_2m becomes
+// "Temperature 2m" and us_aqi_pm2_5 "US AQI PM2.5".
+func variableTitle(variable string) string {
+	words := strings.Split(strings.ReplaceAll(variable, "pm2_5", "PM2.5"), "_")
+	for i, word := range words {
+		if acronym, ok := titleAcronyms[word]; ok {
+			words[i] = acronym
+		}
+	}
+	name := strings.Join(words, " ")
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// The unit settings whose units are listed as imperial.
+var imperialUnitSettings = &WeatherConfig{
+	TemperatureUnit:   "fahrenheit",
+	WindSpeedUnit:     "mph",
+	PrecipitationUnit: "inch",
+}
+
+// A unit and the name of the metric exported with it.
+type UnitMetric struct {
+	Unit       string `json:"unit" yaml:"unit"`
+	MetricName string `json:"metric_name" yaml:"metric_name"`
+}
+
+// VariableInfo describes a variable for the variables command.
+type VariableInfo struct {
+	API         string     `json:"api" yaml:"api"`
+	Name        string     `json:"name" yaml:"name"`
+	Category    string     `json:"category" yaml:"category"`
+	Description string     `json:"description" yaml:"description"`
+	Region      Region     `json:"region" yaml:"region"`
+	ValueType   ValueType  `json:"value_type" yaml:"value_type"`
+	UnitFamily  UnitFamily `json:"unit_family" yaml:"unit_family"`
+	Blocks      []Block    `json:"blocks" yaml:"blocks,flow"`
+	// The unit and metric name with the metric (default) and imperial unit
+	// settings. Air quality variables have the same units in both.
+	Metric   UnitMetric `json:"metric" yaml:"metric"`
+	Imperial UnitMetric `json:"imperial" yaml:"imperial"`
+}
+
+// Returns the variables of an API, or of both APIs when api is empty,
+// sorted by API and name.
+func variableInfos(api string) []VariableInfo {
+	var infos []VariableInfo
+	for _, registry := range []*VariableRegistry{airQualityVariables, weatherVariables} {
+		if api != "" && api != registry.API {
+			continue
+		}
+
+		for _, v := range registry.Variables() {
+			imperial := v.UnitFor(imperialUnitSettings)
+			infos = append(infos, VariableInfo{
+				API:         registry.API,
+				Name:        v.Name,
+				Category:    v.Category,
+				Description: v.Description,
+				Region:      v.Region,
+				ValueType:   v.ValueType,
+				UnitFamily:  v.UnitFamily,
+				Blocks:      v.Blocks,
+				Metric:      UnitMetric{v.Unit, variableMetricName(registry.API, v.Name, v.Unit)},
+				Imperial:    UnitMetric{imperial, variableMetricName(registry.API, v.Name, imperial)},
+			})
+		}
+	}
+	return infos
+}
@@ -0,0 +1,839 @@
+// Code generated by genvariables from variables.yml. DO NOT EDIT.
+
+package main
+
+var weatherVariables = NewVariableRegistry("weather", []Variable{
+	{
+		Name:        "temperature_2m",
+		Category:    "temperature",
+		Unit:        "°C",
+		UnitFamily:  UnitFamilyTemperature,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "temperature",
+		Description: "Air temperature at 2 meters above ground",
+	},
+	{
+		Name:        "relative_humidity_2m",
+		Category:    "humidity",
+		Unit:        "%",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "humidity",
+		Description: "Relative humidity at 2 meters above ground",
+	},
+	{
+		Name:        "dew_point_2m",
+		Category:    "humidity",
+		Unit:        "°C",
+		UnitFamily:  UnitFamilyTemperature,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "temperature",
+		Description: "Dew point temperature at 2 meters above ground",
+	},
+	{
+		Name:        "apparent_temperature",
+		Category:    "temperature",
+		Unit:        "°C",
+		UnitFamily:  UnitFamilyTemperature,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "temperature",
+		Description: "Apparent temperature is the perceived feels-like temperature combining wind chill factor, relative humidity and solar radiation",
+	},
+	{
+		Name:        "pressure_msl",
+		Category:    "pressure",
+		Unit:        "hPa",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "atmospheric_pressure",
+		Description: "Atmospheric air pressure reduced to mean sea level (msl)",
+	},
+	{
+		Name:        "surface_pressure",
+		Category:    "pressure",
+		Unit:        "hPa",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "atmospheric_pressure",
+		Description: "Atmospheric air pressure at surface",
+	},
+	{
+		Name:        "cloud_cover",
+		Category:    "clouds",
+		Unit:        "%",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Total cloud cover as an area fraction",
+	},
+	{
+		Name:        "cloud_cover_low",
+		Category:    "clouds",
+		Unit:        "%",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Low level clouds and fog up to 3 km altitude",
+	},
+	{
+		Name:        "cloud_cover_mid",
+		Category:    "clouds",
+		Unit:        "%",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Mid level clouds from 3 to 8 km altitude",
+	},
+	{
+		Name:        "cloud_cover_high",
+		Category:    "clouds",
+		Unit:        "%",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "High level clouds from 8 km altitude",
+	},
+	{
+		Name:        "wind_speed_10m",
+		Category:    "wind",
+		Unit:        "km/h",
+		UnitFamily:  UnitFamilyWindSpeed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "wind_speed",
+		Description: "Wind speed at 10 meters above ground.",
+	},
+	{
+		Name:        "wind_speed_80m",
+		Category:    "wind",
+		Unit:        "km/h",
+		UnitFamily:  UnitFamilyWindSpeed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "wind_speed",
+		Description: "Wind speed at 80 meters above ground.",
+	},
+	{
+		Name:        "wind_speed_120m",
+		Category:    "wind",
+		Unit:        "km/h",
+		UnitFamily:  UnitFamilyWindSpeed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "wind_speed",
+		Description: "Wind speed at 120 meters above ground.",
+	},
+	{
+		Name:        "wind_speed_180m",
+		Category:    "wind",
+		Unit:        "km/h",
+		UnitFamily:  UnitFamilyWindSpeed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "wind_speed",
+		Description: "Wind speed at 180 meters above ground.",
+	},
+	{
+		Name:        "wind_direction_10m",
+		Category:    "wind",
+		Unit:        "°",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeDirection,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "wind_direction",
+		Description: "Wind direction at 10 meters above ground.",
+	},
+	{
+		Name:        "wind_direction_80m",
+		Category:    "wind",
+		Unit:        "°",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeDirection,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "wind_direction",
+		Description: "Wind direction at 80 meters above ground.",
+	},
+	{
+		Name:        "wind_direction_120m",
+		Category:    "wind",
+		Unit:        "°",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeDirection,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "wind_direction",
+		Description: "Wind direction at 120 meters above ground.",
+	},
+	{
+		Name:        "wind_direction_180m",
+		Category:    "wind",
+		Unit:        "°",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeDirection,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "wind_direction",
+		Description: "Wind direction at 180 meters above ground.",
+	},
+	{
+		Name:        "wind_gusts_10m",
+		Category:    "wind",
+		Unit:        "km/h",
+		UnitFamily:  UnitFamilyWindSpeed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "wind_speed",
+		Description: "Gusts at 10 meters above ground as a maximum of the preceding hour",
+	},
+	{
+		Name:        "shortwave_radiation",
+		Category:    "radiation",
+		Unit:        "W/m²",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "irradiance",
+		Description: "Shortwave solar radiation as average of the preceding hour. This is equal to the total global horizontal irradiation",
+	},
+	{
+		Name:        "direct_radiation",
+		Category:    "radiation",
+		Unit:        "W/m²",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "irradiance",
+		Description: "Direct solar radiation as average of the preceding hour on the horizontal plane",
+	},
+	{
+		Name:        "direct_normal_irradiance",
+		Category:    "radiation",
+		Unit:        "W/m²",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "irradiance",
+		Description: "Direct solar radiation as average of the preceding hour on the normal plane (perpendicular to the sun)",
+	},
+	{
+		Name:        "diffuse_radiation",
+		Category:    "radiation",
+		Unit:        "W/m²",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "irradiance",
+		Description: "Diffuse solar radiation as average of the preceding hour",
+	},
+	{
+		Name:        "vapour_pressure_deficit",
+		Category:    "humidity",
+		Unit:        "kPa",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "pressure",
+		Description: "Vapour Pressure Deficit (VPD) in kilopascal (kPa). For high VPD (>1.6), water transpiration of plants increases. For low VPD (<0.4), transpiration decreases",
+	},
+	{
+		Name:        "cape",
+		Category:    "atmosphere",
+		Unit:        "J/kg",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Convective available potential energy",
+	},
+	{
+		Name:        "evapotranspiration",
+		Category:    "evapotranspiration",
+		Unit:        "mm",
+		UnitFamily:  UnitFamilyPrecipitation,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Evapotranspration from land surface and plants that weather models assumes for this location. Available soil water is considered. 1 mm evapotranspiration per hour equals 1 liter of water per spare meter.",
+	},
+	{
+		Name:        "et0_fao_evapotranspiration",
+		Category:    "evapotranspiration",
+		Unit:        "mm",
+		UnitFamily:  UnitFamilyPrecipitation,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly, BlockDaily},
+		Description: "ET₀ Reference Evapotranspiration of a well watered grass field. Based on FAO-56 Penman-Monteith equations ET₀ is calculated from temperature, wind speed, humidity and solar radiation. Unlimited soil water is assumed. ET₀ is commonly used to estimate the required irrigation for plants.",
+	},
+	{
+		Name:        "precipitation",
+		Category:    "precipitation",
+		Unit:        "mm",
+		UnitFamily:  UnitFamilyPrecipitation,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "precipitation",
+		Description: "Total precipitation (rain, showers, snow) sum of the preceding hour",
+	},
+	{
+		Name:        "snowfall",
+		Category:    "precipitation",
+		Unit:        "cm",
+		UnitFamily:  UnitFamilyPrecipitation,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "precipitation",
+		Description: "Snowfall amount of the preceding hour in centimeters. For the water equivalent in millimeter, divide by 7. E.g. 7 cm snow = 10 mm precipitation water equivalent",
+	},
+	{
+		Name:        "precipitation_probability",
+		Category:    "precipitation",
+		Unit:        "%",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Probability of precipitation with more than 0.1 mm of the preceding hour. Probability is based on ensemble weather models with 0.25° (~27 km) resolution. 30 different simulations are computed to better represent future weather conditions.",
+	},
+	{
+		Name:        "rain",
+		Category:    "precipitation",
+		Unit:        "mm",
+		UnitFamily:  UnitFamilyPrecipitation,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "precipitation",
+		Description: "Rain from large scale weather systems of the preceding hour in millimeter (or inch)",
+	},
+	{
+		Name:        "showers",
+		Category:    "precipitation",
+		Unit:        "mm",
+		UnitFamily:  UnitFamilyPrecipitation,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "precipitation",
+		Description: "Showers from convective precipitation in millimeters from the preceding hour",
+	},
+	{
+		Name:        "weather_code",
+		Category:    "conditions",
+		Unit:        "wmo code",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeCode,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly, BlockDaily},
+		Description: "Weather condition as a numeric code. Follow WMO weather interpretation codes.",
+	},
+	{
+		Name:        "snow_depth",
+		Category:    "precipitation",
+		Unit:        "m",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "distance",
+		Description: "Snow depth on the ground in meters",
+	},
+	{
+		Name:        "freezing_level_height",
+		Category:    "atmosphere",
+		Unit:        "m",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "distance",
+		Description: "Altitude above sea level of the 0°C level",
+	},
+	{
+		Name:        "visibility",
+		Category:    "atmosphere",
+		Unit:        "m",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "distance",
+		Description: "Viewing distance in meters. Influenced by low clouds, humidity and aerosols. Maximum visibility is approximately 24 km.",
+	},
+	{
+		Name:        "soil_temperature_0cm",
+		Category:    "soil",
+		Unit:        "°C",
+		UnitFamily:  UnitFamilyTemperature,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "temperature",
+		Description: "Temperature in the soil at 0 cm depth. 0 cm is the surface temperature on land or water surface temperature on water.",
+	},
+	{
+		Name:        "soil_temperature_6cm",
+		Category:    "soil",
+		Unit:        "°C",
+		UnitFamily:  UnitFamilyTemperature,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "temperature",
+		Description: "Temperature in the soil at 6 cm depth. 0 cm is the surface temperature on land or water surface temperature on water.",
+	},
+	{
+		Name:        "soil_temperature_18cm",
+		Category:    "soil",
+		Unit:        "°C",
+		UnitFamily:  UnitFamilyTemperature,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "temperature",
+		Description: "Temperature in the soil at 18 cm depth. 0 cm is the surface temperature on land or water surface temperature on water.",
+	},
+	{
+		Name:        "soil_temperature_54cm",
+		Category:    "soil",
+		Unit:        "°C",
+		UnitFamily:  UnitFamilyTemperature,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "temperature",
+		Description: "Temperature in the soil at 54 cm depth. 0 cm is the surface temperature on land or water surface temperature on water.",
+	},
+	{
+		Name:        "soil_moisture_0_to_1cm",
+		Category:    "soil",
+		Unit:        "m³/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Average soil water content as volumetric mixing ratio at 0-1 cm depths.",
+	},
+	{
+		Name:        "soil_moisture_1_to_3cm",
+		Category:    "soil",
+		Unit:        "m³/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Average soil water content as volumetric mixing ratio at 1-3 cm depths.",
+	},
+	{
+		Name:        "soil_moisture_3_to_9cm",
+		Category:    "soil",
+		Unit:        "m³/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Average soil water content as volumetric mixing ratio at 3-9 cm depths.",
+	},
+	{
+		Name:        "soil_moisture_9_to_27cm",
+		Category:    "soil",
+		Unit:        "m³/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Average soil water content as volumetric mixing ratio at 9-27 cm depths.",
+	},
+	{
+		Name:        "soil_moisture_27_to_81cm",
+		Category:    "soil",
+		Unit:        "m³/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Average soil water content as volumetric mixing ratio at 27-81 cm depths.",
+	},
+	{
+		Name:        "is_day",
+		Category:    "conditions",
+		Unit:        "",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeFlag,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "1 if the current time step has daylight, 0 at night.",
+	},
+	{
+		Name:        "sunshine_duration",
+		Category:    "radiation",
+		Unit:        "s",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly, BlockDaily},
+		DeviceClass: "duration",
+		Description: "Number of seconds of sunshine of the preceding hour, following the WMO definition of direct normal irradiance exceeding 120 W/m².",
+	},
+	{
+		Name:        "lightning_potential",
+		Category:    "atmosphere",
+		Unit:        "J/kg",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionEurope,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Lightning potential index (LPI) to indicate thunderstorms. Only available in Central Europe with the ICON-D2 model.",
+	},
+})
+
+var airQualityVariables = NewVariableRegistry("airquality", []Variable{
+	{
+		Name:        "pm2_5",
+		Category:    "particulate matter",
+		Unit:        "μg/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "pm25",
+		Description: "Particulate matter with diameter smaller than 2.5 µm (PM2.5) close to surface (10 meter above ground)",
+	},
+	{
+		Name:        "pm10",
+		Category:    "particulate matter",
+		Unit:        "μg/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "pm10",
+		Description: "Particulate matter with diameter smaller than 10 µm (PM10) close to surface (10 meter above ground)",
+	},
+	{
+		Name:        "carbon_monoxide",
+		Category:    "gases",
+		Unit:        "μg/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Carbon monoxide close to surface (10 meter above ground)",
+	},
+	{
+		Name:        "nitrogen_dioxide",
+		Category:    "gases",
+		Unit:        "μg/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "nitrogen_dioxide",
+		Description: "Nitrogen dioxide close to surface (10 meter above ground)",
+	},
+	{
+		Name:        "sulphur_dioxide",
+		Category:    "gases",
+		Unit:        "μg/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "sulphur_dioxide",
+		Description: "Sulphur dioxide close to surface (10 meter above ground)",
+	},
+	{
+		Name:        "ozone",
+		Category:    "gases",
+		Unit:        "μg/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "ozone",
+		Description: "Ozone close to surface (10 meter above ground)",
+	},
+	{
+		Name:        "ammonia",
+		Category:    "gases",
+		Unit:        "μg/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionEurope,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Ammonia concentration. Only available for Europe.",
+	},
+	{
+		Name:        "aerosol_optical_depth",
+		Category:    "aerosols",
+		Unit:        "",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Aerosol optical depth at 550 nm of the entire atmosphere to indicate haze.",
+	},
+	{
+		Name:        "dust",
+		Category:    "aerosols",
+		Unit:        "μg/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Saharan dust particles close to surface level (10 meter above ground).",
+	},
+	{
+		Name:        "uv_index",
+		Category:    "uv",
+		Unit:        "",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "UV index considering clouds. See ECMWF UV Index recommendation for more information",
+	},
+	{
+		Name:        "uv_index_clear_sky",
+		Category:    "uv",
+		Unit:        "",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "UV index considering clear sky. See ECMWF UV Index recommendation for more information",
+	},
+	{
+		Name:        "alder_pollen",
+		Category:    "pollen",
+		Unit:        "grains/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionEurope,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Pollen for various plants. Only available in Europe as provided by CAMS European Air Quality forecast.",
+	},
+	{
+		Name:        "birch_pollen",
+		Category:    "pollen",
+		Unit:        "grains/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionEurope,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Pollen for various plants. Only available in Europe as provided by CAMS European Air Quality forecast.",
+	},
+	{
+		Name:        "grass_pollen",
+		Category:    "pollen",
+		Unit:        "grains/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionEurope,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Pollen for various plants. Only available in Europe as provided by CAMS European Air Quality forecast.",
+	},
+	{
+		Name:        "mugwort_pollen",
+		Category:    "pollen",
+		Unit:        "grains/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionEurope,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Pollen for various plants. Only available in Europe as provided by CAMS European Air Quality forecast.",
+	},
+	{
+		Name:        "olive_pollen",
+		Category:    "pollen",
+		Unit:        "grains/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionEurope,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Pollen for various plants. Only available in Europe as provided by CAMS European Air Quality forecast.",
+	},
+	{
+		Name:        "ragweed_pollen",
+		Category:    "pollen",
+		Unit:        "grains/m³",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeMeasurement,
+		Region:      RegionEurope,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		Description: "Pollen for various plants. Only available in Europe as provided by CAMS European Air Quality forecast.",
+	},
+	{
+		Name:        "european_aqi",
+		Category:    "european aqi",
+		Unit:        "EAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "European Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated european_aqi returns the maximum of all individual indices. Ranges from 0-20 (good), 20-40 (fair), 40-60 (moderate), 60-80 (poor), 80-100 (very poor) and exceeds 100 for extremely poor conditions.",
+	},
+	{
+		Name:        "european_aqi_pm2_5",
+		Category:    "european aqi",
+		Unit:        "EAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "European Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated european_aqi returns the maximum of all individual indices. Ranges from 0-20 (good), 20-40 (fair), 40-60 (moderate), 60-80 (poor), 80-100 (very poor) and exceeds 100 for extremely poor conditions.",
+	},
+	{
+		Name:        "european_aqi_pm10",
+		Category:    "european aqi",
+		Unit:        "EAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "European Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated european_aqi returns the maximum of all individual indices. Ranges from 0-20 (good), 20-40 (fair), 40-60 (moderate), 60-80 (poor), 80-100 (very poor) and exceeds 100 for extremely poor conditions.",
+	},
+	{
+		Name:        "european_aqi_nitrogen_dioxide",
+		Category:    "european aqi",
+		Unit:        "EAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "European Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated european_aqi returns the maximum of all individual indices. Ranges from 0-20 (good), 20-40 (fair), 40-60 (moderate), 60-80 (poor), 80-100 (very poor) and exceeds 100 for extremely poor conditions.",
+	},
+	{
+		Name:        "european_aqi_ozone",
+		Category:    "european aqi",
+		Unit:        "EAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "European Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated european_aqi returns the maximum of all individual indices. Ranges from 0-20 (good), 20-40 (fair), 40-60 (moderate), 60-80 (poor), 80-100 (very poor) and exceeds 100 for extremely poor conditions.",
+	},
+	{
+		Name:        "european_aqi_sulphur_dioxide",
+		Category:    "european aqi",
+		Unit:        "EAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "European Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated european_aqi returns the maximum of all individual indices. Ranges from 0-20 (good), 20-40 (fair), 40-60 (moderate), 60-80 (poor), 80-100 (very poor) and exceeds 100 for extremely poor conditions.",
+	},
+	{
+		Name:        "us_aqi",
+		Category:    "us aqi",
+		Unit:        "USAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "United States Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated us_aqi returns the maximum of all individual indices. Ranges from 0-50 (good), 51-100 (moderate), 101-150 (unhealthy for sensitive groups), 151-200 (unhealthy), 201-300 (very unhealthy) and 301-500 (hazardous).",
+	},
+	{
+		Name:        "us_aqi_pm2_5",
+		Category:    "us aqi",
+		Unit:        "USAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "United States Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated us_aqi returns the maximum of all individual indices. Ranges from 0-50 (good), 51-100 (moderate), 101-150 (unhealthy for sensitive groups), 151-200 (unhealthy), 201-300 (very unhealthy) and 301-500 (hazardous).",
+	},
+	{
+		Name:        "us_aqi_pm10",
+		Category:    "us aqi",
+		Unit:        "USAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "United States Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated us_aqi returns the maximum of all individual indices. Ranges from 0-50 (good), 51-100 (moderate), 101-150 (unhealthy for sensitive groups), 151-200 (unhealthy), 201-300 (very unhealthy) and 301-500 (hazardous).",
+	},
+	{
+		Name:        "us_aqi_nitrogen_dioxide",
+		Category:    "us aqi",
+		Unit:        "USAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "United States Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated us_aqi returns the maximum of all individual indices. Ranges from 0-50 (good), 51-100 (moderate), 101-150 (unhealthy for sensitive groups), 151-200 (unhealthy), 201-300 (very unhealthy) and 301-500 (hazardous).",
+	},
+	{
+		Name:        "us_aqi_ozone",
+		Category:    "us aqi",
+		Unit:        "USAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "United States Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated us_aqi returns the maximum of all individual indices. Ranges from 0-50 (good), 51-100 (moderate), 101-150 (unhealthy for sensitive groups), 151-200 (unhealthy), 201-300 (very unhealthy) and 301-500 (hazardous).",
+	},
+	{
+		Name:        "us_aqi_sulphur_dioxide",
+		Category:    "us aqi",
+		Unit:        "USAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "United States Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated us_aqi returns the maximum of all individual indices. Ranges from 0-50 (good), 51-100 (moderate), 101-150 (unhealthy for sensitive groups), 151-200 (unhealthy), 201-300 (very unhealthy) and 301-500 (hazardous).",
+	},
+	{
+		Name:        "us_aqi_carbon_monoxide",
+		Category:    "us aqi",
+		Unit:        "USAQI",
+		UnitFamily:  UnitFamilyFixed,
+		ValueType:   ValueTypeIndex,
+		Region:      RegionGlobal,
+		Blocks:      []Block{BlockCurrent, BlockHourly},
+		DeviceClass: "aqi",
+		Description: "United States Air Quality Index (AQI) calculated for different particulate matter and gases individually. The consolidated us_aqi returns the maximum of all individual indices. Ranges from 0-50 (good), 51-100 (moderate), 101-150 (unhealthy for sensitive groups), 151-200 (unhealthy), 201-300 (very unhealthy) and 301-500 (hazardous).",
+	},
+})
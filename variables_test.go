@@ -0,0 +1,167 @@
+/*
+Copyright 2024 Thomas Helander
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLookupVariable(t *testing.T) {
+	tests := []struct {
+		api, name   string
+		unit        string
+		valueType   ValueType
+		region      Region
+		averageable bool
+		err         string
+	}{
+		{api: "weather", name: "temperature_2m", unit: "°C", valueType: ValueTypeMeasurement, region: RegionGlobal, averageable: true},
+		{api: "weather", name: "wind_direction_10m", unit: "°", valueType: ValueTypeDirection, region: RegionGlobal},
+		{api: "weather", name: "weather_code", unit: "wmo code", valueType: ValueTypeCode, region: RegionGlobal},
+		{api: "airquality", name: "alder_pollen", unit: "grains/m³", valueType: ValueTypeMeasurement, region: RegionEurope, averageable: true},
+		{api: "airquality", name: "us_aqi", unit: "USAQI", valueType: ValueTypeIndex, region: RegionGlobal, averageable: true},
+		// Variables of the other API are not found.
+		{api: "airquality", name: "temperature_2m", err: "invalid airquality variable name: temperature_2m"},
+		{api: "weather", name: "pm2_5", err: "invalid weather variable name: pm2_5"},
+		{api: "weather", name: "", err: "invalid weather variable name: "},
+		{api: "marine", name: "wave_height", err: "unknown api: marine"},
+	}
+	for _, tt := range tests {
+		v, err := LookupVariable(tt.api, tt.name)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s %s: expected error %q, got %v", tt.api, tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s: unexpected error: %v", tt.api, tt.name, err)
+			continue
+		}
+		if v.Name != tt.name || v.Unit != tt.unit || v.ValueType != tt.valueType || v.Region != tt.region || v.Averageable() != tt.averageable {
+			t.Errorf("%s %s: unexpected variable %+v", tt.api, tt.name, v)
+		}
+	}
+}
+
+func TestVariableSupports(t *testing.T) {
+	v, err := LookupVariable("weather", "weather_code")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, block := range []Block{BlockCurrent, BlockHourly, BlockDaily} {
+		if !v.Supports(block) {
+			t.Errorf("weather_code should support %s", block)
+		}
+	}
+	if (Variable{Blocks: []Block{BlockHourly}}).Supports(BlockDaily) {
+		t.Error("an hourly variable should not support daily")
+	}
+}
+
+func TestVariableUnitFor(t *testing.T) {
+	tests := []struct {
+		api, name string
+		config    *WeatherConfig
+		want      string
+	}{
+		{"weather", "temperature_2m", nil, "°C"},
+		{"weather", "temperature_2m", &WeatherConfig{TemperatureUnit: "celsius"}, "°C"},
+		{"weather", "temperature_2m", &WeatherConfig{TemperatureUnit: "fahrenheit"}, "°F"},
+		{"weather", "wind_speed_10m", &WeatherConfig{}, "km/h"},
+		{"weather", "wind_speed_10m", &WeatherConfig{WindSpeedUnit: "mph"}, "mp/h"},
+		{"weather", "wind_speed_10m", &WeatherConfig{WindSpeedUnit: "ms"}, "m/s"},
+		{"weather", "wind_speed_10m", &WeatherConfig{WindSpeedUnit: "kn"}, "kn"},
+		{"weather", "precipitation", &WeatherConfig{PrecipitationUnit: "inch"}, "inch"},
+		// Units that don't depend on a setting are never converted.
+		{"weather", "wind_direction_10m", imperialUnitSettings, "°"},
+		{"weather", "temperature_2m", &WeatherConfig{WindSpeedUnit: "mph"}, "°C"},
+		{"airquality", "pm2_5", imperialUnitSettings, "μg/m³"},
+	}
+	for _, tt := range tests {
+		v, err := LookupVariable(tt.api, tt.name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := v.UnitFor(tt.config); got != tt.want {
+			t.Errorf("%s with %+v: got %q, want %q", tt.name, tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestVariableRegistry(t *testing.T) {
+	for _, api := range []string{"weather", "airquality"} {
+		registry := variableRegistry(api)
+		if registry == nil || registry.API != api {
+			t.Fatalf("unexpected registry for %s: %+v", api, registry)
+		}
+		variables := registry.Variables()
+		if len(variables) == 0 {
+			t.Fatalf("no %s variables", api)
+		}
+		if !slices.IsSortedFunc(variables, func(a, b Variable) int { return strings.Compare(a.Name, b.Name) }) {
+			t.Errorf("%s variables are not sorted", api)
+		}
+		for _, v := range variables {
+			if v.Description == "" || len(v.Blocks) == 0 {
+				t.Errorf("%s variable %s has no description or blocks", api, v.Name)
+			}
+			if found, ok := registry.Lookup(v.Name); !ok || found.Name != v.Name {
+				t.Errorf("%s variable %s was not found", api, v.Name)
+			}
+		}
+	}
+	if registry := variableRegistry("marine"); registry != nil {
+		t.Errorf("unexpected registry for an unknown api: %+v", registry)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected duplicate variables to panic")
+		}
+	}()
+	NewVariableRegistry("weather", []Variable{{Name: "temperature_2m"}, {Name: "temperature_2m"}})
+}
+
+func TestVariableDescription(t *testing.T) {
+	if got := variableDescription("weather", "temperature_2m"); got != "Air temperature at 2 meters above ground" {
+		t.Errorf("unexpected description %q", got)
+	}
+	// Variables outside the catalogue are accepted with lenient_variables.
+	if got := variableDescription("weather", "temperature_2m_ecmwf"); got != "The weather variable temperature_2m_ecmwf of the Open-Meteo API." {
+		t.Errorf("unexpected fallback description %q", got)
+	}
+}
+
+func TestVariableTitle(t *testing.T) {
+	tests := map[string]string{
+		"temperature_2m":     "Temperature 2m",
+		"us_aqi":             "US AQI",
+		"us_aqi_pm2_5":       "US AQI PM2.5",
+		"european_aqi_pm10":  "European AQI PM10",
+		"pm2_5":              "PM2.5",
+		"uv_index_clear_sky": "UV index clear sky",
+		"cape":               "CAPE",
+		"pressure_msl":       "Pressure MSL",
+	}
+	for variable, want := range tests {
+		if got := variableTitle(variable); got != want {
+			t.Errorf("variableTitle(%q) = %q, want %q", variable, got, want)
+		}
+	}
+}